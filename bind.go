@@ -1,10 +1,14 @@
 // Copyright 2018 Terence Tarvis.  All rights reserved.
-//  
+//
 
 package main
 
 import (
 	"go/ast"
+	"go/token"
+	"go/types"
+	"net"
+	"regexp"
 	"strings"
 )
 
@@ -15,32 +19,275 @@ func init() {
 		callExpr)
 }
 
-func bindCheck(f *File, node ast.Node) {
-	var names []string
-	var callName string
-	if call, ok := node.(*ast.CallExpr); ok {
-		if fun, ok := call.Fun.(*ast.SelectorExpr); ok {
-			// SelectorExpr has two fields
-			// X and Sel
-			// X (through reflection) was found to be an Ident
-			// Ident's have a field Name also.
-			if id, ok := (fun.X).(*ast.Ident); ok {
-				names = append(names, id.Name);
-				names = append(names, fun.Sel.Name);
-				callName = strings.Join(names, "/");
-				if(callName == "net/Listen" || callName == "tls/Listen") {
-					if len(call.Args) > 1 { // just a check to be sure
-						if basicLit, ok := call.Args[1].(*ast.BasicLit); ok {
-							if(strings.Contains(basicLit.Value, "0.0.0.0")) {
-								callStr := f.ASTString(call);
-								f.Reportf(node.Pos(), "audit binding network listener to all interfaces: %s", callStr);
-							}
-						}
+// listenCalls take an address to listen on as a string, at the index given
+var listenCalls = map[string]int{
+	"net.Listen":				1,
+	"net.ListenPacket":			1,
+	"crypto/tls.Listen":			1,
+	"(*net.ListenConfig).Listen":		2,
+	"(*net.ListenConfig).ListenPacket":	2,
+	"net/http.ListenAndServe":		0,
+	"net/http.ListenAndServeTLS":		0,
+}
+
+// serverListenCalls listen on an http.Server's Addr, and the address used when it is left out.
+// Serve and ServeTLS take a listener, which is checked where it is made, and ignore Addr
+var serverListenCalls = map[string]string{
+	"(*net/http.Server).ListenAndServe":	":http",
+	"(*net/http.Server).ListenAndServeTLS":	":https",
+}
+
+// listenAddrCalls take an address to listen on as a *net.TCPAddr, *net.UDPAddr or *net.IPAddr
+var listenAddrCalls = map[string]int{
+	"net.ListenTCP":	1,
+	"net.ListenUDP":	1,
+	"net.ListenIP":		1,
+}
+
+// unspecifiedIPs are the net package's variables for the unspecified address
+var unspecifiedIPs = map[string]bool{
+	"net.IPv4zero":		true,
+	"net.IPv6zero":		true,
+	"net.IPv6unspecified":	true,
+}
+
+// unknownPart stands in for the parts of an address that can't be worked out
+const unknownPart = "\x00"
+
+// maxResolveDepth stops following variables that are assigned from each other
+const maxResolveDepth = 8
+
+// formatVerb finds the verbs in a format string
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// getAssignedValue returns the only value a variable is given in the package,
+// or nil if it is given none or more than one.
+// a variable given the first result of a call gets the call
+func getAssignedValue(f *File, obj types.Object) ast.Expr {
+	var values []ast.Expr
+	for _, file := range f.pkg.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.AssignStmt:
+				for i, lhs := range x.Lhs {
+					if getObject(f, lhs) != obj {
+						continue;
+					}
+					switch {
+					case x.Tok != token.ASSIGN && x.Tok != token.DEFINE:
+						// v += x can't be followed
+						values = append(values, nil, nil);
+					case len(x.Lhs) == len(x.Rhs):
+						values = append(values, x.Rhs[i]);
+					case i == 0:
+						// the first result of v, err := f() is given as the call
+						values = append(values, x.Rhs[0]);
+					default:
+						values = append(values, nil, nil);
 					}
-				}	
+				}
+			case *ast.ValueSpec:
+				for i, name := range x.Names {
+					if f.pkg.info.Defs[name] == obj && len(x.Values) == len(x.Names) {
+						values = append(values, x.Values[i]);
+					}
+				}
+			}
+			return true;
+		});
+	}
+	if len(values) != 1 {
+		return nil;
+	}
+	return values[0];
+}
+
+// resolveString works out the value of a string expression through constants,
+// variables assigned once, concatenation, net.JoinHostPort and fmt.Sprintf.
+// parts it can't work out are replaced with unknownPart
+func resolveString(f *File, x ast.Expr, depth int) string {
+	if s, ok := getConstString(f, x); ok {
+		return s;
+	}
+	if depth > maxResolveDepth {
+		return unknownPart;
+	}
+	switch e := unparen(x).(type) {
+	case *ast.Ident:
+		if obj := getObject(f, e); obj != nil {
+			if value := getAssignedValue(f, obj); value != nil {
+				return resolveString(f, value, depth + 1);
+			}
+		}
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			return resolveString(f, e.X, depth + 1) + resolveString(f, e.Y, depth + 1);
+		}
+	case *ast.CallExpr:
+		switch getCalleeName(f, e) {
+		case "net.JoinHostPort":
+			if len(e.Args) == 2 {
+				host := resolveString(f, e.Args[0], depth + 1);
+				if strings.Contains(host, ":") {
+					host = "[" + host + "]";
+				}
+				return host + ":" + resolveString(f, e.Args[1], depth + 1);
+			}
+		case "fmt.Sprintf":
+			if len(e.Args) > 0 {
+				format := resolveString(f, e.Args[0], depth + 1);
+				return formatVerb.ReplaceAllString(format, unknownPart);
 			}
 		}
 	}
+	return unknownPart;
+}
 
-	return;
+// isUnspecifiedHost checks if a host listens on every interface,
+// an empty host, 0.0.0.0 or ::
+func isUnspecifiedHost(host string) bool {
+	if host == "" {
+		return true;
+	}
+	ip := net.ParseIP(host);
+	return ip != nil && ip.IsUnspecified();
+}
+
+// bindsAllInterfaces checks if a listen address, as worked out by resolveString,
+// has a host that listens on every interface, i.e. ":8080", "0.0.0.0:80" or "[::]:80".
+// an empty address listens on every interface on a default or random port
+func bindsAllInterfaces(addr string) bool {
+	if addr == "" {
+		return true;
+	}
+	host, _, err := net.SplitHostPort(addr);
+	if err != nil {
+		return false;
+	}
+	return !strings.Contains(host, unknownPart) && isUnspecifiedHost(host);
+}
+
+// isUnspecifiedIP checks if an expression is an unspecified or nil net.IP
+func isUnspecifiedIP(f *File, x ast.Expr, depth int) bool {
+	if isNil(f, x) {
+		return true;
+	}
+	switch e := unparen(x).(type) {
+	case *ast.SelectorExpr:
+		if v, ok := f.pkg.info.Uses[e.Sel].(*types.Var); ok && v.Pkg() != nil {
+			return unspecifiedIPs[v.Pkg().Path() + "." + v.Name()];
+		}
+	case *ast.Ident:
+		if obj := getObject(f, e); obj != nil && depth < maxResolveDepth {
+			if value := getAssignedValue(f, obj); value != nil {
+				return isUnspecifiedIP(f, value, depth + 1);
+			}
+		}
+	case *ast.CallExpr:
+		switch getCalleeName(f, e) {
+		case "net.ParseIP":
+			if len(e.Args) == 1 {
+				s := resolveString(f, e.Args[0], depth + 1);
+				return !strings.Contains(s, unknownPart) && isUnspecifiedHost(s);
+			}
+		case "net.IPv4":
+			for _, arg := range e.Args {
+				if v, ok := getConstInt(f, arg); !ok || v != 0 {
+					return false;
+				}
+			}
+			return true;
+		}
+	}
+	return false;
+}
+
+// isUnspecifiedAddr checks if a *net.TCPAddr, *net.UDPAddr or *net.IPAddr
+// listens on every interface. nil or a missing IP does
+func isUnspecifiedAddr(f *File, x ast.Expr, depth int) bool {
+	if isNil(f, x) {
+		return true;
+	}
+	if depth > maxResolveDepth {
+		return false;
+	}
+	switch e := unparen(x).(type) {
+	case *ast.UnaryExpr:
+		return isUnspecifiedAddr(f, e.X, depth + 1);
+	case *ast.CompositeLit:
+		ip, ok := getStructFields(f, e)["IP"];
+		return !ok || isUnspecifiedIP(f, ip, depth + 1);
+	case *ast.Ident:
+		if obj := getObject(f, e); obj != nil {
+			if value := getAssignedValue(f, obj); value != nil {
+				return isUnspecifiedAddr(f, value, depth + 1);
+			}
+		}
+	case *ast.CallExpr:
+		name := getCalleeName(f, e);
+		if (name == "net.ResolveTCPAddr" || name == "net.ResolveUDPAddr" || name == "net.ResolveIPAddr") && len(e.Args) == 2 {
+			return bindsAllInterfaces(resolveString(f, e.Args[1], depth + 1));
+		}
+	}
+	return false;
+}
+
+// getServerLit returns the http.Server literal a ListenAndServe receiver was built from,
+// i.e. srv in srv := &http.Server{Addr: ":8080"}, or nil if it can't be followed
+func getServerLit(f *File, x ast.Expr, depth int) *ast.CompositeLit {
+	if depth > maxResolveDepth {
+		return nil;
+	}
+	switch e := unparen(x).(type) {
+	case *ast.CompositeLit:
+		if isNamedType(f.pkg.info.TypeOf(e), "net/http", "Server") {
+			return e;
+		}
+	case *ast.UnaryExpr:
+		return getServerLit(f, e.X, depth + 1);
+	case *ast.Ident:
+		if obj := getObject(f, e); obj != nil {
+			if value := getAssignedValue(f, obj); value != nil {
+				return getServerLit(f, value, depth + 1);
+			}
+		}
+	}
+	return nil;
+}
+
+// bindCheck looks for listeners bound to all interfaces through every standard
+// way of listening, with the address worked out from constants and variables
+func bindCheck(f *File, node ast.Node) {
+	switch x := node.(type) {
+	case *ast.CallExpr:
+		name := getCalleeName(f, x);
+		if index, ok := listenCalls[name]; ok && index < len(x.Args) {
+			if bindsAllInterfaces(resolveString(f, x.Args[index], 0)) {
+				f.Reportf(x.Pos(), "audit binding network listener to all interfaces: %s", f.ASTString(x));
+			}
+			return;
+		}
+		if index, ok := listenAddrCalls[name]; ok && index < len(x.Args) {
+			if isUnspecifiedAddr(f, x.Args[index], 0) {
+				f.Reportf(x.Pos(), "audit binding network listener to all interfaces: %s", f.ASTString(x));
+			}
+			return;
+		}
+		// srv := &http.Server{Addr: ":8080"}; srv.ListenAndServe()
+		defaultAddr, ok := serverListenCalls[name];
+		sel, isSel := x.Fun.(*ast.SelectorExpr);
+		if !ok || !isSel {
+			return;
+		}
+		lit := getServerLit(f, sel.X, 0);
+		if lit == nil {
+			return;
+		}
+		addr, ok := getStructFields(f, lit)["Addr"];
+		if !ok {
+			f.Reportf(x.Pos(), "audit binding network listener to all interfaces: http.Server without Addr listens on %s: %s", defaultAddr, f.ASTString(x));
+		} else if bindsAllInterfaces(resolveString(f, addr, 0)) {
+			f.Reportf(x.Pos(), "audit binding network listener to all interfaces: Addr: %s: %s", f.ASTString(addr), f.ASTString(x));
+		}
+	}
 }
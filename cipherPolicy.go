@@ -0,0 +1,134 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
+)
+
+// CipherPolicy decides which cipher suites may be configured
+type CipherPolicy struct {
+	name	string
+	// allowed is nil if every suite crypto/tls considers secure is allowed
+	allowed	map[uint16]bool
+}
+
+// tls13Suites can't be configured in crypto/tls but are allowed by every policy
+// in case someone lists them anyway
+var tls13Suites = []uint16{
+	tls.TLS_AES_128_GCM_SHA256,
+	tls.TLS_AES_256_GCM_SHA384,
+	tls.TLS_CHACHA20_POLY1305_SHA256,
+}
+
+// cipherPolicies are the built in policies.
+// modern and intermediate follow the Mozilla server side TLS recommendations.
+// modern only allows TLS 1.3 so any TLS 1.2 suite is reported.
+// go allows anything crypto/tls doesn't consider insecure.
+var cipherPolicies = map[string][]uint16{
+	"modern":	tls13Suites,
+	"intermediate":	append([]uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	}, tls13Suites...),
+	"go":		nil,
+}
+
+var cipherPolicy *CipherPolicy
+
+// setCipherPolicy builds the cipher policy from configuration
+func setCipherPolicy(conf TLSPolicyConfig) error {
+	if len(conf.CipherSuites) != 0 {
+		allowed := make(map[uint16]bool);
+		for _, name := range conf.CipherSuites {
+			id, err := parseCipherSuite(name);
+			if err != nil {
+				return err;
+			}
+			allowed[id] = true;
+		}
+		cipherPolicy = &CipherPolicy{name: "custom", allowed: allowed};
+		return nil;
+	}
+	ids, ok := cipherPolicies[conf.CipherPolicy];
+	if !ok {
+		return fmt.Errorf("unknown cipher policy %q", conf.CipherPolicy);
+	}
+	cipherPolicy = &CipherPolicy{name: conf.CipherPolicy};
+	if ids != nil {
+		cipherPolicy.allowed = make(map[uint16]bool);
+		for _, id := range ids {
+			cipherPolicy.allowed[id] = true;
+		}
+	}
+	return nil;
+}
+
+// parseCipherSuite takes a cipher suite name as crypto/tls spells it
+// or a hex id like 0xc02f
+func parseCipherSuite(s string) (uint16, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		id, err := strconv.ParseUint(s, 0, 16);
+		if err != nil {
+			return 0, fmt.Errorf("bad cipher suite id %q: %v", s, err);
+		}
+		return uint16(id), nil;
+	}
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if suite.Name == s {
+				return suite.ID, nil;
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", s);
+}
+
+// isInsecureSuite checks if crypto/tls considers a suite to have security issues
+func isInsecureSuite(id uint16) bool {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isSecureSuite checks if a suite is one crypto/tls implements without known issues
+func isSecureSuite(id uint16) bool {
+	for _, suite := range tls.CipherSuites() {
+		if suite.ID == id {
+			return true;
+		}
+	}
+	return false;
+}
+
+// checkCipherSuite checks one element of a tls.Config CipherSuites list against the policy
+func checkCipherSuite(f *File, x ast.Expr) {
+	val, ok := getConstInt(f, x);
+	if !ok || val < 0 || val > 0xffff {
+		return;
+	}
+	id := uint16(val);
+	name := tls.CipherSuiteName(id);
+	switch {
+	case cipherPolicy.allowed[id]:
+		// explicitly allowed, even if crypto/tls disagrees
+	case isInsecureSuite(id):
+		f.Reportf(x.Pos(), "Weak cipher, %s, is in use", name);
+	case !isSecureSuite(id):
+		f.Reportf(x.Pos(), "Unknown cipher, %s, is in use", name);
+	case cipherPolicy.allowed != nil && !cipherPolicy.allowed[id]:
+		f.Reportf(x.Pos(), "Cipher %s is not allowed by the %s cipher policy", name, cipherPolicy.name);
+	}
+}
@@ -4,6 +4,11 @@ package main
 
 import (
 	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ssa"
 )
 
 func init() {
@@ -13,18 +18,429 @@ func init() {
 		funcDecl)
 }
 
-func opensFile(f *File, x ast.Expr) bool {
-	/*
-	if(f.pkg.info.TypeOf(x) == nil) {
-		// should probably print something out here to notify the user
+// ioCloser is io.Closer, built here so it doesn't depend on io being loaded
+var ioCloser = types.NewInterfaceType([]*types.Func{
+	types.NewFunc(token.NoPos, nil, "Close", types.NewSignature(nil, nil,
+		types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type())), false)),
+}, nil).Complete()
+
+// nonOwningCalls return closers that belong to something else,
+// closing them is the job of whatever they wrap or came from
+var nonOwningCalls = map[string]bool{
+	"io.NopCloser":				true,
+	"io/ioutil.NopCloser":			true,
+	"net/http.MaxBytesReader":		true,
+	"(*os/exec.Cmd).StdoutPipe":		true,
+	"(*os/exec.Cmd).StderrPipe":		true,
+	"(*os/exec.Cmd).StdinPipe":		true,
+}
+
+// closesParam caches whether a function closes or keeps hold of each of its parameters
+var closesParam = make(map[*ssa.Function][]bool)
+
+// isCloser checks if a type has a Close() error method
+func isCloser(t types.Type) bool {
+	return types.Implements(t, ioCloser) || isHTTPResponse(t);
+}
+
+// isHTTPResponse checks for *http.Response, whose Body needs closing
+func isHTTPResponse(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer);
+	if !ok {
 		return false;
 	}
-	*/
-	/*
-	if(f.pkg.info.TypeOf(x).String() == "(*os.File, error)") {
-		return true
+	named, ok := ptr.Elem().(*types.Named);
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "net/http" && named.Obj().Name() == "Response";
+}
+
+// closerResource is a value that needs closing, and the error returned with it if any.
+// value is nil if the closer is never assigned to anything
+type closerResource struct {
+	call	*ssa.Call
+	value	ssa.Value
+	err	ssa.Value
+}
+
+// getResources finds the closers a function gets from the calls it makes
+func getResources(fn *ssa.Function) []closerResource {
+	var resources []closerResource
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call);
+			if !ok {
+				continue;
+			}
+			if callee := call.Call.StaticCallee(); callee != nil && nonOwningCalls[callee.String()] {
+				continue;
+			}
+			tuple, ok := call.Type().(*types.Tuple);
+			if !ok {
+				if isCloser(call.Type()) {
+					resources = append(resources, closerResource{call: call, value: call});
+				}
+				continue;
+			}
+			// f, err := os.Open(name)
+			var res closerResource
+			res.call = call;
+			hasCloser := false;
+			for i := 0; i < tuple.Len(); i++ {
+				if isCloser(tuple.At(i).Type()) {
+					hasCloser = true;
+				}
+			}
+			if !hasCloser {
+				continue;
+			}
+			refs := call.Referrers();
+			for _, ref := range *refs {
+				ex, ok := ref.(*ssa.Extract);
+				if !ok {
+					continue;
+				}
+				t := tuple.At(ex.Index).Type();
+				switch {
+				case isCloser(t):
+					res.value = ex;
+				case types.Identical(t, types.Universe.Lookup("error").Type()):
+					res.err = ex;
+				}
+			}
+			// a nil value means the closer was thrown away
+			resources = append(resources, res);
+		}
+	}
+	return resources;
+}
+
+// isCloseCall checks if a call closes v
+func isCloseCall(common *ssa.CallCommon, v ssa.Value) bool {
+	if common.IsInvoke() {
+		return common.Value == v && common.Method.Name() == "Close";
+	}
+	callee := common.StaticCallee();
+	return callee != nil && callee.Name() == "Close" && callee.Signature.Recv() != nil && len(common.Args) > 0 && common.Args[0] == v;
+}
+
+// getHandlers returns the instructions that close v or hand it on to something
+// else that will, i.e. returning it, storing it in a struct or passing it to a helper
+func getHandlers(v ssa.Value) map[ssa.Instruction]bool {
+	handlers := make(map[ssa.Instruction]bool);
+	seen := make(map[ssa.Value]bool);
+	work := []ssa.Value{v};
+	for len(work) > 0 {
+		a := work[len(work) - 1];
+		work = work[:len(work) - 1];
+		if seen[a] {
+			continue;
+		}
+		seen[a] = true;
+		refs := a.Referrers();
+		if refs == nil {
+			continue;
+		}
+		for _, ref := range *refs {
+			switch instr := ref.(type) {
+			case *ssa.Phi, *ssa.MakeInterface, *ssa.ChangeType, *ssa.ChangeInterface, *ssa.TypeAssert:
+				// the same value under another name
+				work = append(work, instr.(ssa.Value));
+			case *ssa.FieldAddr:
+				// resp.Body
+				if isHTTPResponse(a.Type()) && getFieldName(instr) == "Body" {
+					work = append(work, loadsOf(instr)...);
+				}
+			case *ssa.Store:
+				if instr.Val != a {
+					continue;
+				}
+				// a local variable, captured by a closure or taken the address of
+				if alloc, ok := instr.Addr.(*ssa.Alloc); ok {
+					work = append(work, loadsOf(alloc)...);
+					if closureCloses(alloc) {
+						handlers[instr] = true;
+					}
+					continue;
+				}
+				// a struct field, slice element or global owns it now
+				handlers[instr] = true;
+			case *ssa.Return, *ssa.Send, *ssa.MapUpdate:
+				handlers[instr] = true;
+			case *ssa.MakeClosure:
+				if closureCloses(a) {
+					handlers[instr] = true;
+				}
+			case ssa.CallInstruction:
+				common := instr.Common();
+				if isCloseCall(common, a) || passesOwnership(common, a) {
+					handlers[instr] = true;
+				}
+			}
+		}
 	}
-	*/
+	return handlers;
+}
+
+// getFieldName returns the name of the field a FieldAddr points to
+func getFieldName(fa *ssa.FieldAddr) string {
+	ptr, ok := fa.X.Type().Underlying().(*types.Pointer);
+	if !ok {
+		return "";
+	}
+	st, ok := ptr.Elem().Underlying().(*types.Struct);
+	if !ok || fa.Field >= st.NumFields() {
+		return "";
+	}
+	return st.Field(fa.Field).Name();
+}
+
+// loadsOf returns the loads of an address in its function
+func loadsOf(addr ssa.Value) []ssa.Value {
+	var loads []ssa.Value
+	refs := addr.Referrers();
+	if refs == nil {
+		return nil;
+	}
+	for _, ref := range *refs {
+		if load, ok := ref.(*ssa.UnOp); ok && load.Op == token.MUL && load.X == addr {
+			loads = append(loads, load);
+		}
+	}
+	return loads;
+}
+
+// closureCloses checks if a closure bound to v closes it
+// i.e. defer func() { f.Close() }()
+func closureCloses(v ssa.Value) bool {
+	refs := v.Referrers();
+	if refs == nil {
+		return false;
+	}
+	for _, ref := range *refs {
+		mc, ok := ref.(*ssa.MakeClosure);
+		if !ok {
+			continue;
+		}
+		fn, ok := mc.Fn.(*ssa.Function);
+		if !ok {
+			continue;
+		}
+		for i, binding := range mc.Bindings {
+			if binding != v || i >= len(fn.FreeVars) {
+				continue;
+			}
+			free := ssa.Value(fn.FreeVars[i]);
+			if _, ok := v.(*ssa.Alloc); ok {
+				// captured by reference, so look at what is loaded from it
+				for _, load := range loadsOf(free) {
+					if len(getHandlers(load)) != 0 {
+						return true;
+					}
+				}
+				continue;
+			}
+			if len(getHandlers(free)) != 0 {
+				return true;
+			}
+		}
+	}
+	return false;
+}
+
+// passesOwnership checks if a call is given a closer and closes or keeps it.
+// functions in the same package are looked at, anything else is assumed to take
+// ownership only if the parameter has a Close method, i.e. io.ReadCloser but not io.Reader
+func passesOwnership(common *ssa.CallCommon, v ssa.Value) bool {
+	// the receiver isn't in Args for an interface method call
+	if common.IsInvoke() && common.Value == v {
+		return false;
+	}
+	callee := common.StaticCallee();
+	for i, arg := range common.Args {
+		if arg != v {
+			continue;
+		}
+		// a method call on v, f.Read(buf) doesn't close f
+		if callee != nil && callee.Signature.Recv() != nil && i == 0 {
+			continue;
+		}
+		if callee != nil && len(callee.Blocks) != 0 && v.Parent() != nil && callee.Pkg == v.Parent().Pkg {
+			if closesParamAt(callee, i) {
+				return true;
+			}
+			continue;
+		}
+		sig := common.Signature();
+		paramIndex := i;
+		if callee != nil && sig.Recv() != nil {
+			paramIndex--;
+		}
+		if paramIndex >= 0 && paramIndex < sig.Params().Len() {
+			t := sig.Params().At(paramIndex).Type();
+			if sig.Variadic() && paramIndex == sig.Params().Len() - 1 {
+				continue;
+			}
+			if types.IsInterface(t) && types.Implements(t, ioCloser) {
+				return true;
+			}
+		}
+	}
+	return false;
+}
+
+// closesParamAt checks if a function in the package closes or keeps hold of a parameter
+func closesParamAt(fn *ssa.Function, i int) bool {
+	results, ok := closesParam[fn];
+	if !ok {
+		// guard against recursion while this is worked out
+		results = make([]bool, len(fn.Params));
+		closesParam[fn] = results;
+		for j, param := range fn.Params {
+			results[j] = len(getHandlers(param)) != 0;
+		}
+	}
+	return i < len(results) && results[i];
+}
+
+// isNilConst checks for a nil constant
+func isNilConst(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const);
+	return ok && c.IsNil();
+}
+
+// getGuardedSucc works out which branch of an if a resource is live on.
+// if err != nil, or f == nil, only leads to the resource on one side.
+// it returns -1 if both sides need following
+func getGuardedSucc(res closerResource, ifInstr *ssa.If) int {
+	cond, ok := ifInstr.Cond.(*ssa.BinOp);
+	if !ok || (cond.Op != token.EQL && cond.Op != token.NEQ) {
+		return -1;
+	}
+	x, y := cond.X, cond.Y;
+	if isNilConst(x) {
+		x, y = y, x;
+	}
+	if !isNilConst(y) {
+		return -1;
+	}
+	switch {
+	case res.err != nil && x == res.err:
+		// the resource is good when err == nil
+		if cond.Op == token.EQL {
+			return 0;
+		}
+		return 1;
+	case x == res.value:
+		// the resource is good when it isn't nil
+		if cond.Op == token.NEQ {
+			return 0;
+		}
+		return 1;
+	}
+	return -1;
+}
+
+// leaks checks for a path from where a resource is opened to a return
+// that doesn't go through anything that closes it or hands it on
+func leaks(res closerResource, handlers map[ssa.Instruction]bool) bool {
+	visited := make(map[*ssa.BasicBlock]bool);
+	var walk func(b *ssa.BasicBlock, start int) bool
+	walk = func(b *ssa.BasicBlock, start int) bool {
+		for _, instr := range b.Instrs[start:] {
+			if handlers[instr] {
+				return false;
+			}
+			switch x := instr.(type) {
+			case *ssa.Return:
+				return true;
+			case *ssa.Panic:
+				return false;
+			case *ssa.If:
+				if i := getGuardedSucc(res, x); i >= 0 {
+					succ := b.Succs[i];
+					if visited[succ] {
+						return false;
+					}
+					visited[succ] = true;
+					return walk(succ, 0);
+				}
+			}
+		}
+		for _, succ := range b.Succs {
+			if visited[succ] {
+				continue;
+			}
+			visited[succ] = true;
+			if walk(succ, 0) {
+				return true;
+			}
+		}
+		return false;
+	};
+	b := res.call.Block();
+	for i, instr := range b.Instrs {
+		if instr == res.call {
+			return walk(b, i + 1);
+		}
+	}
+	return false;
+}
+
+// getSSAFunc finds the SSA function for a declaration
+func getSSAFunc(f *File, fun *ast.FuncDecl) *ssa.Function {
+	if f.pkg.lp == nil || f.pkg.ssaProg == nil || len(f.pkg.lp.Created) == 0 {
+		return nil;
+	}
+	obj, ok := f.pkg.lp.Created[0].Info.Defs[fun.Name].(*types.Func);
+	if !ok {
+		return nil;
+	}
+	fn := f.pkg.ssaProg.FuncValue(obj);
+	if fn == nil || len(fn.Blocks) == 0 {
+		return nil;
+	}
+	return fn;
+}
+
+// getCloserType returns the type of the closer a call returns
+func getCloserType(call *ssa.Call) types.Type {
+	if tuple, ok := call.Type().(*types.Tuple); ok {
+		for i := 0; i < tuple.Len(); i++ {
+			if isCloser(tuple.At(i).Type()) {
+				return tuple.At(i).Type();
+			}
+		}
+	}
+	return call.Type();
+}
+
+// getCallString returns the source of the call at a position, for reporting
+func getCallString(f *File, pos token.Pos) string {
+	path, _ := astutil.PathEnclosingInterval(f.file, pos, pos);
+	for _, n := range path {
+		if call, ok := n.(*ast.CallExpr); ok {
+			return f.ASTString(call);
+		}
+	}
+	return "";
+}
+
+// checkCloserSSA looks for closers that aren't closed or handed on along every path
+func checkCloserSSA(f *File, fn *ssa.Function) {
+	for _, res := range getResources(fn) {
+		if res.value == nil {
+			f.Reportf(res.call.Pos(), "Audit for Close() method called on %s, it is discarded: %s", getCloserType(res.call), getCallString(f, res.call.Pos()));
+			continue;
+		}
+		if leaks(res, getHandlers(res.value)) {
+			f.Reportf(res.call.Pos(), "Audit for Close() method called on %s, it is not closed on every path: %s", res.value.Type(), getCallString(f, res.call.Pos()));
+		}
+	}
+	for _, anon := range fn.AnonFuncs {
+		checkCloserSSA(f, anon);
+	}
+}
+
+func opensFile(f *File, x ast.Expr) bool {
 	if typeValue := f.pkg.info.TypeOf(x); typeValue != nil {
 		if typeValue.String() == "(*os.File, error)" {
 			return true;
@@ -33,82 +449,77 @@ func opensFile(f *File, x ast.Expr) bool {
 	return false;
 }
 
-// closesFile checks the remaining statements in a function body for a .Close() method
+// closesFile checks the remaining statements in a function body for a .Close() method,
+// deferred or not, or for the file being returned
 func closesFile(f *File, stmts []ast.Stmt) bool {
+	closes := false;
 	for _, stmt := range stmts {
-		switch expr := stmt.(type) {
-		case *ast.AssignStmt:
-			rhs := expr.Rhs;
-			for _, x := range rhs {
-				name, err := getFullFuncName(x);
-				if err != nil {
-					warnf("issue, %v", err);
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.CallExpr:
+				if getFuncName(x) == "Close" {
+					closes = true;
 				}
-				if(name == "file/Close") {
-					return true
+			case *ast.ReturnStmt:
+				for _, result := range x.Results {
+					if t := f.pkg.info.TypeOf(result); t != nil && t.String() == "*os.File" {
+						closes = true;
+					}
 				}
 			}
-		case *ast.ExprStmt:
-			name, err := getFullFuncName(expr.X);
-			if err != nil {
-				warnf("issue, %v", err);
-			}
-			if(name == "file/Close") {
-				return true
-			}
-		}
+			return !closes;
+		});
 	}
-	return false
+	return closes;
 }
 
-// for the time being this just checks a function to see if an opened file is closed
-// http.MaxBytesReader should also be checked for a close
-func closeCheck(f *File, node ast.Node) {
+// closeCheckAST is used when the package can't be built into SSA.
+// it checks a function to see if an opened file is closed
+func closeCheckAST(f *File, fun *ast.FuncDecl) {
 	var formatString string = "Audit for Close() method called on opened file, %s"
-	// loop through block
-	// look for file open
-	// look for file close
-	// if no file close, report
-	// consider checking if the opened file is returned
-	// consider checking if an open file is an input and no file is returned
-	// it turns out you can open a file and not use it. What then?
-	// ugh I really hate this
-	// is walking the statements a better option?
-	if fun, ok := node.(*ast.FuncDecl); ok {
-		// todo: check that this prevents crashes
-		if (fun.Body == nil || fun.Body.List == nil) { return; }
-		for i, stmts := range fun.Body.List {
-			switch stmt := stmts.(type) {
-			case *ast.AssignStmt:
-				rhs := stmt.Rhs;
-				for _, x := range rhs {
-					if(opensFile(f, x)) {
-						if(!closesFile(f, fun.Body.List[i:])) {
-							f.Reportf(stmt.Pos(), formatString,f.ASTString(x))
-						}
-					}
-				}
-			case *ast.ExprStmt:
-				if(opensFile(f, stmt.X)) {
+	for i, stmts := range fun.Body.List {
+		switch stmt := stmts.(type) {
+		case *ast.AssignStmt:
+			rhs := stmt.Rhs;
+			for _, x := range rhs {
+				if(opensFile(f, x)) {
 					if(!closesFile(f, fun.Body.List[i:])) {
-						f.Reportf(stmt.Pos(), formatString, f.ASTString(stmt.X))
+						f.Reportf(stmt.Pos(), formatString,f.ASTString(x))
 					}
 				}
-			case *ast.IfStmt:
-				if s, ok := stmt.Init.(*ast.AssignStmt); ok {
-					rhs := s.Rhs;
-					for _, x := range rhs {
-						if(opensFile(f, x )) {
-							if(!closesFile(f, fun.Body.List[i:])) {
-								f.Reportf(stmt.Pos(), formatString, f.ASTString(x))
-							}
+			}
+		case *ast.ExprStmt:
+			if(opensFile(f, stmt.X)) {
+				if(!closesFile(f, fun.Body.List[i:])) {
+					f.Reportf(stmt.Pos(), formatString, f.ASTString(stmt.X))
+				}
+			}
+		case *ast.IfStmt:
+			if s, ok := stmt.Init.(*ast.AssignStmt); ok {
+				rhs := s.Rhs;
+				for _, x := range rhs {
+					if(opensFile(f, x )) {
+						if(!closesFile(f, fun.Body.List[i:])) {
+							f.Reportf(stmt.Pos(), formatString, f.ASTString(x))
 						}
 					}
 				}
-			default:
-				// do nothing for time being
 			}
 		}
 	}
+}
+
+// closeCheck looks for anything implementing io.Closer, or an http.Response,
+// that is opened in a function and not closed, returned or stored on every path
+func closeCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil || fun.Body.List == nil {
+		return;
+	}
+	if fn := getSSAFunc(f, fun); fn != nil {
+		checkCloserSSA(f, fn);
+		return;
+	}
+	closeCheckAST(f, fun);
 	return;
 }
@@ -0,0 +1,152 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Config holds settings for individual checks.
+// It is read from a JSON file given with the config flag.
+// Anything left out of the file keeps its default.
+type Config struct {
+	TLS	TLSPolicyConfig	`json:"tls"`
+	Crypto	CryptoConfig	`json:"crypto"`
+	Secrets	SecretsConfig	`json:"secrets"`
+	Hardcoded	HardcodedConfig	`json:"hardcoded"`
+	Errors	ErrorsConfig	`json:"errors"`
+}
+
+// TLSPolicyConfig configures the TLSConfig check
+type TLSPolicyConfig struct {
+	// CipherPolicy names a built in cipher suite policy.
+	// see cipherPolicies for the names
+	CipherPolicy	string		`json:"cipherPolicy"`
+	// CipherSuites is a custom allowlist of cipher suite names or hex ids.
+	// if set, it is used instead of CipherPolicy
+	CipherSuites	[]string	`json:"cipherSuites"`
+	// MinVersion is the oldest TLS version allowed, i.e. 1.2.
+	// see tlsVersions for the names
+	MinVersion	string		`json:"minVersion"`
+}
+
+// CryptoConfig configures the minimums used by the weakCryptoParams check
+type CryptoConfig struct {
+	MinRSABits		int	`json:"minRSABits"`
+	MinBcryptCost		int	`json:"minBcryptCost"`
+	MinPBKDF2Iterations	int	`json:"minPBKDF2Iterations"`
+	MinSaltLength		int	`json:"minSaltLength"`
+	MinScryptN		int	`json:"minScryptN"`
+	MinScryptR		int	`json:"minScryptR"`
+	MinScryptP		int	`json:"minScryptP"`
+}
+
+// SecretsConfig describes secret values for the timingCompare check
+type SecretsConfig struct {
+	// Types are full type names, i.e. example.com/auth.Token,
+	// whose values are secret whatever they are called
+	Types	[]string	`json:"types"`
+}
+
+// HardcodedConfig configures the hardcoded check
+type HardcodedConfig struct {
+	// MinScore is the lowest credential score that gets reported.
+	// see scoreCredential for how the score is worked out
+	MinScore		int		`json:"minScore"`
+	MinEntropy		float64		`json:"minEntropy"`
+	MinNormalisedEntropy	float64		`json:"minNormalisedEntropy"`
+	// Allow are regular expressions for literals that are never reported
+	Allow			[]string	`json:"allow"`
+	// ExcludePaths are globs of files not to check, i.e. testdata/* or *_test.go
+	ExcludePaths		[]string	`json:"excludePaths"`
+}
+
+// ErrorsConfig configures the error check
+type ErrorsConfig struct {
+	// Exclude are functions whose errors can be ignored, by fully qualified name,
+	// i.e. fmt.Println or (*bytes.Buffer).Write, or whole packages by import path
+	Exclude	[]string	`json:"exclude"`
+}
+
+var config = Config{
+	TLS: TLSPolicyConfig{
+		CipherPolicy:	"intermediate",
+		MinVersion:	"1.2",
+	},
+	// these follow the OWASP password storage recommendations
+	Crypto: CryptoConfig{
+		MinRSABits:		2048,
+		MinBcryptCost:		10,
+		MinPBKDF2Iterations:	600000,
+		MinSaltLength:		16,
+		MinScryptN:		1 << 15,
+		MinScryptR:		8,
+		MinScryptP:		1,
+	},
+	Hardcoded: HardcodedConfig{
+		MinScore:		2,
+		MinEntropy:		2.5,
+		MinNormalisedEntropy:	.98,
+	},
+}
+
+// loadConfig reads the configuration file, if any, and sets up
+// anything the checks need from it before they run
+func loadConfig(path string) error {
+	if path != "" {
+		data, err := os.ReadFile(path);
+		if err != nil {
+			return err;
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return err;
+		}
+	}
+	if err := setHardcodedPolicy(config.Hardcoded); err != nil {
+		return err;
+	}
+	if err := setMinTLSVersion(config.TLS); err != nil {
+		return err;
+	}
+	return setCipherPolicy(config.TLS);
+}
+
+// setHardcodedPolicy compiles the allowlist and checks the path globs
+func setHardcodedPolicy(c HardcodedConfig) error {
+	allowedLits = nil;
+	for _, pattern := range c.Allow {
+		re, err := regexp.Compile(pattern);
+		if err != nil {
+			return fmt.Errorf("bad hardcoded allow pattern %q: %v", pattern, err);
+		}
+		allowedLits = append(allowedLits, re);
+	}
+	for _, glob := range c.ExcludePaths {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("bad hardcoded exclude path %q: %v", glob, err);
+		}
+	}
+	return nil;
+}
+
+// matchesPath checks if a file path matches any of the globs.
+// a glob matches the whole path or any trailing part of it,
+// so testdata/* matches a/b/testdata/c.go
+func matchesPath(path string, globs []string) bool {
+	path = filepath.ToSlash(path);
+	parts := strings.Split(path, "/");
+	for _, glob := range globs {
+		for i := range parts {
+			if ok, _ := filepath.Match(glob, strings.Join(parts[i:], "/")); ok {
+				return true;
+			}
+		}
+	}
+	return false;
+}
@@ -0,0 +1,304 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"net/http"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func init() {
+	register("cookies",
+		"this tests for cookies without Secure, HttpOnly or SameSite, long lived sessions and weak session keys",
+		cookieCheck,
+		callExpr,
+		compositeLit)
+}
+
+// maxSessionAge is the longest a session cookie should live, in seconds
+const maxSessionAge = 7 * 24 * 60 * 60
+
+// minSessionKeyLen is the shortest key for authenticating session cookies,
+// gorilla/securecookie recommends 32 or 64 bytes
+const minSessionKeyLen = 32
+
+// sessionCookieNames are words of cookie names that hold a session or credentials
+var sessionCookieNames = []string{
+	"session", "sess", "sid", "auth", "token", "jwt", "login", "remember",
+	"sessionid", "phpsessid", "jsessionid",
+}
+
+// sessionKeyCalls take keys for signing session cookies, starting at the argument given.
+// for gorilla/sessions stores keys come in pairs of an authentication key and an optional encryption key
+var sessionKeyCalls = map[string]int{
+	"github.com/gorilla/sessions.NewCookieStore":		0,
+	"github.com/gorilla/sessions.NewFilesystemStore":	1,
+	"github.com/gorilla/securecookie.New":			0,
+	"github.com/gorilla/csrf.Protect":			0,
+}
+
+// isFalseOrUnset checks if a bool field is left out or constant false
+func isFalseOrUnset(f *File, fields map[string]ast.Expr, name string) bool {
+	x, ok := fields[name];
+	if !ok {
+		return true;
+	}
+	val, ok := getConstBool(f, x);
+	return ok && !val;
+}
+
+// isSessionCookie checks if a cookie name looks like it holds a session
+func isSessionCookie(name string) bool {
+	return hasNameWord(name, sessionCookieNames...);
+}
+
+// checkCookieFields checks the fields of an http.Cookie or sessions.Options, reporting at x.
+// session is true for cookies known to hold a session, others are judged by their name
+func checkCookieFields(f *File, x ast.Node, fields map[string]ast.Expr, session bool) {
+	desc := "cookie";
+	if nameExpr, ok := fields["Name"]; ok {
+		if name, ok := getConstString(f, nameExpr); ok {
+			desc = fmt.Sprintf("cookie %q", name);
+			session = session || isSessionCookie(name);
+		}
+	}
+	var missing []string
+	if isFalseOrUnset(f, fields, "Secure") {
+		missing = append(missing, "Secure");
+	}
+	if isFalseOrUnset(f, fields, "HttpOnly") {
+		missing = append(missing, "HttpOnly");
+	}
+	if sameSite, ok := fields["SameSite"]; !ok {
+		missing = append(missing, "SameSite");
+	} else if mode, ok := getConstInt(f, sameSite); ok && (mode == 0 || mode == int64(http.SameSiteDefaultMode)) {
+		missing = append(missing, "SameSite");
+	}
+	if len(missing) > 0 {
+		f.Reportf(x.Pos(), "%s without %s", desc, strings.Join(missing, ", "));
+	}
+	if maxAge, ok := fields["MaxAge"]; ok && session {
+		if age, ok := getConstInt(f, maxAge); ok && age > maxSessionAge {
+			f.Reportf(x.Pos(), "session %s lasts %d days, stolen sessions stay valid that long: MaxAge: %s", desc, age / (24 * 60 * 60), f.ASTString(maxAge));
+		}
+	}
+}
+
+// stripAddr removes parentheses and a leading & from an expression, i.e. &http.Cookie{} or (&c)
+func stripAddr(x ast.Expr) ast.Expr {
+	x = unparen(x);
+	if u, ok := x.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		return unparen(u.X);
+	}
+	return x;
+}
+
+// sendsCookie checks if body gives a cookie picked out by isCookie to a response,
+// through http.SetCookie or a Set-Cookie header set from its String method
+func sendsCookie(f *File, body ast.Node, isCookie func(ast.Expr) bool) bool {
+	found := false;
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || found {
+			return !found;
+		}
+		switch getCalleeName(f, call) {
+		case "net/http.SetCookie":
+			found = len(call.Args) == 2 && isCookie(stripAddr(call.Args[1]));
+		case "(net/http.Header).Set", "(net/http.Header).Add":
+			if len(call.Args) != 2 {
+				break;
+			}
+			if key, ok := getConstString(f, call.Args[0]); !ok || !strings.EqualFold(key, "Set-Cookie") {
+				break;
+			}
+			// w.Header().Add("Set-Cookie", c.String())
+			if str, ok := unparen(call.Args[1]).(*ast.CallExpr); ok && getFuncName(str) == "String" {
+				if sel, ok := str.Fun.(*ast.SelectorExpr); ok {
+					found = isCookie(stripAddr(sel.X));
+				}
+			}
+		}
+		return !found;
+	});
+	return found;
+}
+
+// isResponseCookie checks if an http.Cookie literal, or the variable it is assigned to,
+// is sent in a response in the function it is made in. Cookies added to requests
+// or a cookie jar are for the client side, where Secure, HttpOnly and SameSite mean nothing
+func isResponseCookie(f *File, lit *ast.CompositeLit) bool {
+	path, _ := astutil.PathEnclosingInterval(f.file, lit.Pos(), lit.End());
+	var obj types.Object
+	var body ast.Node
+	for _, n := range path[1:] {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if obj == nil && len(x.Lhs) == len(x.Rhs) {
+				for j, rhs := range x.Rhs {
+					if stripAddr(rhs) == lit {
+						obj = getObject(f, x.Lhs[j]);
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			if obj == nil && len(x.Names) == len(x.Values) {
+				for j, value := range x.Values {
+					if stripAddr(value) == lit {
+						obj = f.pkg.info.Defs[x.Names[j]];
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			body = x.Body;
+		case *ast.FuncLit:
+			body = x.Body;
+		}
+		if body != nil {
+			break;
+		}
+	}
+	if body == nil {
+		return false;
+	}
+	return sendsCookie(f, body, func(x ast.Expr) bool {
+		if x == lit {
+			return true;
+		}
+		id, ok := x.(*ast.Ident);
+		return ok && obj != nil && getObject(f, id) == obj;
+	});
+}
+
+// getZeroCookie returns the variable a SetCookie argument refers to if it starts out
+// as a zero http.Cookie with its fields set afterwards, i.e. var c http.Cookie or new(http.Cookie).
+// cookies made from literals are checked at the literal
+func getZeroCookie(f *File, x ast.Expr) types.Object {
+	x = unparen(x);
+	if u, ok := x.(*ast.UnaryExpr); ok {
+		x = unparen(u.X);
+	}
+	id, ok := x.(*ast.Ident);
+	if !ok {
+		return nil;
+	}
+	obj := getObject(f, id);
+	if obj == nil {
+		return nil;
+	}
+	value := getAssignedValue(f, obj);
+	if value == nil {
+		// a var with no value, not a parameter or a result of a call
+		path, _ := astutil.PathEnclosingInterval(f.file, obj.Pos(), obj.Pos());
+		for _, n := range path {
+			if spec, ok := n.(*ast.ValueSpec); ok && len(spec.Values) == 0 {
+				return obj;
+			}
+		}
+		return nil;
+	}
+	if call, ok := value.(*ast.CallExpr); ok && getFuncName(call) == "new" {
+		return obj;
+	}
+	return nil;
+}
+
+// checkSetCookie checks a cookie passed to http.SetCookie whose fields are set one by one
+func checkSetCookie(f *File, call *ast.CallExpr) {
+	if len(call.Args) != 2 {
+		return;
+	}
+	obj := getZeroCookie(f, call.Args[1]);
+	if obj == nil {
+		return;
+	}
+	path, _ := astutil.PathEnclosingInterval(f.file, call.Pos(), call.End());
+	for _, n := range path {
+		var body ast.Node
+		switch fun := n.(type) {
+		case *ast.FuncDecl:
+			body = fun.Body;
+		case *ast.FuncLit:
+			body = fun.Body;
+		}
+		if body != nil {
+			fields := make(map[string]ast.Expr);
+			addAssignedFields(f, body, obj, fields);
+			checkCookieFields(f, call, fields, false);
+			return;
+		}
+	}
+}
+
+// getKeyLen works out the length of a session key and whether it is a constant,
+// following a variable to the value it is given
+func getKeyLen(f *File, x ast.Expr) (int64, bool, bool) {
+	if id, ok := unparen(x).(*ast.Ident); ok {
+		if obj := getObject(f, id); obj != nil {
+			if value := getAssignedValue(f, obj); value != nil {
+				x = value;
+			}
+		}
+	}
+	if call, ok := unparen(x).(*ast.CallExpr); ok && getCalleeName(f, call) == "github.com/gorilla/securecookie.GenerateRandomKey" && len(call.Args) == 1 {
+		n, ok := getConstInt(f, call.Args[0]);
+		return n, false, ok;
+	}
+	return getByteLen(f, unparen(x));
+}
+
+// checkSessionKeys checks the keys given to a session store for being hardcoded or short
+func checkSessionKeys(f *File, call *ast.CallExpr, first int) {
+	name := getCalleeName(f, call);
+	for i := first; i < len(call.Args); i++ {
+		if call.Ellipsis.IsValid() {
+			break;
+		}
+		length, isConst, ok := getKeyLen(f, call.Args[i]);
+		if !ok {
+			continue;
+		}
+		if isConst {
+			f.Reportf(call.Args[i].Pos(), "hardcoded session key passed to %s: %s", name[strings.LastIndex(name, "/") + 1:], f.ASTString(call.Args[i]));
+			continue;
+		}
+		// the keys after the first authentication key alternate between encryption and authentication
+		auth := (i - first) % 2 == 0;
+		if auth && length < minSessionKeyLen {
+			f.Reportf(call.Args[i].Pos(), "session authentication key is %d bytes, use at least %d: %s", length, minSessionKeyLen, f.ASTString(call.Args[i]));
+		}
+	}
+}
+
+// cookieCheck looks for http.Cookie literals sent in responses, cookies set with http.SetCookie
+// and gorilla/sessions options without Secure, HttpOnly or SameSite, session cookies
+// that live too long, and session stores with hardcoded or short keys
+func cookieCheck(f *File, node ast.Node) {
+	switch x := node.(type) {
+	case *ast.CompositeLit:
+		t := f.pkg.info.TypeOf(x);
+		switch {
+		case isNamedType(t, "net/http", "Cookie"):
+			if isResponseCookie(f, x) {
+				checkCookieFields(f, x, getStructFields(f, x), false);
+			}
+		case isNamedType(t, "github.com/gorilla/sessions", "Options"):
+			checkCookieFields(f, x, getStructFields(f, x), true);
+		}
+	case *ast.CallExpr:
+		name := getCalleeName(f, x);
+		if name == "net/http.SetCookie" {
+			checkSetCookie(f, x);
+			return;
+		}
+		if first, ok := sessionKeyCalls[name]; ok {
+			checkSessionKeys(f, x, first);
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func init() {
+	register("cors",
+		"this tests for CORS headers and configuration that let any site make credentialed requests, and WebSocket upgraders that accept any origin",
+		corsCheck,
+		callExpr,
+		compositeLit)
+}
+
+// headerSetters set a header given as their first argument to their second
+var headerSetters = map[string]bool{
+	"(net/http.Header).Set":	true,
+	"(net/http.Header).Add":	true,
+}
+
+// getLitTypeName returns the full name of a composite literal's type, i.e. github.com/rs/cors.Options.
+// the import path is used when the package couldn't be loaded
+func getLitTypeName(f *File, lit *ast.CompositeLit) string {
+	t := f.pkg.info.TypeOf(lit);
+	if t != nil {
+		if ptr, ok := t.Underlying().(*types.Pointer); ok {
+			t = ptr.Elem();
+		}
+		if named, ok := types.Unalias(t).(*types.Named); ok && named.Obj().Pkg() != nil {
+			return named.Obj().Pkg().Path() + "." + named.Obj().Name();
+		}
+	}
+	if sel, ok := lit.Type.(*ast.SelectorExpr); ok {
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if pkgName, ok := f.pkg.info.Uses[id].(*types.PkgName); ok {
+				return pkgName.Imported().Path() + "." + sel.Sel.Name;
+			}
+		}
+	}
+	return "";
+}
+
+// alwaysReturnsTrue checks if a callback, either a function literal or
+// a function declared in the package, returns true on every path
+func alwaysReturnsTrue(f *File, x ast.Expr) bool {
+	body := getFuncBody(f, x);
+	if body == nil {
+		return false;
+	}
+	returns := 0;
+	onlyTrue := true;
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.FuncLit:
+			return false;
+		case *ast.ReturnStmt:
+			returns++;
+			if len(stmt.Results) != 1 || !isConstTrue(f, stmt.Results[0]) {
+				onlyTrue = false;
+			}
+		}
+		return onlyTrue;
+	});
+	return returns > 0 && onlyTrue;
+}
+
+// isConstTrue checks for a constant true, or the identifier true when type info is missing
+func isConstTrue(f *File, x ast.Expr) bool {
+	if val, ok := getConstBool(f, x); ok {
+		return val;
+	}
+	id, ok := x.(*ast.Ident);
+	return ok && id.Name == "true";
+}
+
+// allowsAnyOrigin checks if a list of allowed origins, a []string literal, includes *
+func allowsAnyOrigin(f *File, x ast.Expr) bool {
+	lit, ok := unparen(x).(*ast.CompositeLit);
+	if !ok {
+		return false;
+	}
+	for _, elt := range lit.Elts {
+		if s, ok := getConstString(f, elt); ok && s == "*" {
+			return true;
+		}
+		if b, ok := elt.(*ast.BasicLit); ok && b.Kind == token.STRING && b.Value == `"*"` {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isOriginHeader checks if an expression reads the request's Origin header,
+// directly with r.Header.Get("Origin") or through a variable holding it
+func isOriginHeader(f *File, x ast.Expr, depth int) bool {
+	switch e := unparen(x).(type) {
+	case *ast.CallExpr:
+		if getCalleeName(f, e) == "(net/http.Header).Get" && len(e.Args) == 1 {
+			key, ok := getConstString(f, e.Args[0]);
+			return ok && strings.EqualFold(key, "Origin");
+		}
+	case *ast.IndexExpr:
+		// r.Header["Origin"][0]
+		if idx, ok := unparen(e.X).(*ast.IndexExpr); ok && isNamedType(f.pkg.info.TypeOf(idx.X), "net/http", "Header") {
+			key, ok := getConstString(f, idx.Index);
+			return ok && strings.EqualFold(key, "Origin");
+		}
+	case *ast.Ident:
+		if obj := getObject(f, e); obj != nil && depth < maxResolveDepth {
+			if value := getAssignedValue(f, obj); value != nil {
+				return isOriginHeader(f, value, depth + 1);
+			}
+		}
+	}
+	return false;
+}
+
+// isOriginChecked checks if a header is only set inside an if or switch
+// whose condition looks at the origin, i.e. an allowlist check
+func isOriginChecked(f *File, call *ast.CallExpr, origin ast.Expr) bool {
+	var obj types.Object
+	if id, ok := unparen(origin).(*ast.Ident); ok {
+		obj = getObject(f, id);
+	}
+	refersToOrigin := func(n ast.Node) bool {
+		found := false;
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.BinaryExpr:
+				// origin != "" only checks there is one
+				if x.Op == token.EQL || x.Op == token.NEQ {
+					if s, ok := getConstString(f, x.X); ok && s == "" {
+						return false;
+					}
+					if s, ok := getConstString(f, x.Y); ok && s == "" {
+						return false;
+					}
+				}
+			case *ast.Ident:
+				found = found || (obj != nil && f.pkg.info.Uses[x] == obj);
+			case ast.Expr:
+				found = found || isOriginHeader(f, x, maxResolveDepth);
+			}
+			return !found;
+		});
+		return found;
+	};
+	path, _ := astutil.PathEnclosingInterval(f.file, call.Pos(), call.End());
+	for _, n := range path {
+		switch s := n.(type) {
+		case *ast.IfStmt:
+			if refersToOrigin(s.Cond) {
+				return true;
+			}
+		case *ast.SwitchStmt:
+			if s.Tag != nil && refersToOrigin(s.Tag) {
+				return true;
+			}
+		case *ast.CaseClause:
+			for _, x := range s.List {
+				if refersToOrigin(x) {
+					return true;
+				}
+			}
+		case *ast.FuncDecl, *ast.FuncLit:
+			return false;
+		}
+	}
+	return false;
+}
+
+// getHeaderValues returns the constant values a function sets a header to
+func getHeaderValues(f *File, body ast.Node, header string) []string {
+	var values []string
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || !headerSetters[getCalleeName(f, call)] || len(call.Args) != 2 {
+			return true;
+		}
+		if key, ok := getConstString(f, call.Args[0]); ok && strings.EqualFold(key, header) {
+			if val, ok := getConstString(f, call.Args[1]); ok {
+				values = append(values, val);
+			}
+		}
+		return true;
+	});
+	return values;
+}
+
+// allowsCredentials checks if the function a call is in sets Access-Control-Allow-Credentials: true
+func allowsCredentials(f *File, call *ast.CallExpr) bool {
+	path, _ := astutil.PathEnclosingInterval(f.file, call.Pos(), call.End());
+	for _, n := range path {
+		var body ast.Node
+		switch fun := n.(type) {
+		case *ast.FuncDecl:
+			body = fun.Body;
+		case *ast.FuncLit:
+			body = fun.Body;
+		}
+		if body == nil {
+			continue;
+		}
+		for _, val := range getHeaderValues(f, body, "Access-Control-Allow-Credentials") {
+			if strings.EqualFold(val, "true") {
+				return true;
+			}
+		}
+		return false;
+	}
+	return false;
+}
+
+// checkCORSHeader checks a header being set for an Access-Control-Allow-Origin
+// that lets any site read responses
+func checkCORSHeader(f *File, call *ast.CallExpr) {
+	if len(call.Args) != 2 {
+		return;
+	}
+	key, ok := getConstString(f, call.Args[0]);
+	if !ok || !strings.EqualFold(key, "Access-Control-Allow-Origin") {
+		return;
+	}
+	credentials := allowsCredentials(f, call);
+	if val, ok := getConstString(f, call.Args[1]); ok {
+		if val == "*" && credentials {
+			f.Reportf(call.Pos(), "Access-Control-Allow-Origin: * with Access-Control-Allow-Credentials: true, browsers refuse it and it is usually \"fixed\" by reflecting the Origin: %s", f.ASTString(call));
+		}
+		return;
+	}
+	if isOriginHeader(f, call.Args[1], 0) && !isOriginChecked(f, call, call.Args[1]) {
+		if credentials {
+			f.Reportf(call.Pos(), "Access-Control-Allow-Origin reflects the request Origin with credentials allowed, any site can make authenticated requests and read the responses: %s", f.ASTString(call));
+		} else {
+			f.Reportf(call.Pos(), "Access-Control-Allow-Origin reflects the request Origin, any site can read the responses: %s", f.ASTString(call));
+		}
+	}
+}
+
+// checkCORSConfig checks the options of the rs/cors and gin-contrib/cors middleware
+// for any origin being allowed along with credentials
+func checkCORSConfig(f *File, lit *ast.CompositeLit, fields map[string]ast.Expr) {
+	credentials, ok := fields["AllowCredentials"];
+	if !ok || !isConstTrue(f, credentials) {
+		return;
+	}
+	anyOrigin := "";
+	if x, ok := fields["AllowAllOrigins"]; ok && isConstTrue(f, x) {
+		anyOrigin = "AllowAllOrigins";
+	}
+	for _, name := range []string{"AllowedOrigins", "AllowOrigins"} {
+		if x, ok := fields[name]; ok && allowsAnyOrigin(f, x) {
+			anyOrigin = name + " *";
+		}
+	}
+	for _, name := range []string{"AllowOriginFunc", "AllowOriginRequestFunc", "AllowOriginVaryRequestFunc"} {
+		if x, ok := fields[name]; ok && alwaysReturnsTrue(f, x) {
+			anyOrigin = name + " always returning true";
+		}
+	}
+	if anyOrigin != "" {
+		f.Reportf(lit.Pos(), "CORS configuration allows any origin, %s, with AllowCredentials, any site can make authenticated requests and read the responses", anyOrigin);
+	}
+}
+
+// corsCheck looks for Access-Control-Allow-Origin headers and CORS middleware
+// configuration that let any site make credentialed requests, origins reflected
+// from the request, and WebSocket upgraders whose CheckOrigin accepts anything
+func corsCheck(f *File, node ast.Node) {
+	switch x := node.(type) {
+	case *ast.CallExpr:
+		if headerSetters[getCalleeName(f, x)] {
+			checkCORSHeader(f, x);
+		}
+	case *ast.CompositeLit:
+		switch getLitTypeName(f, x) {
+		case "github.com/rs/cors.Options", "github.com/gin-contrib/cors.Config":
+			checkCORSConfig(f, x, getStructFields(f, x));
+		case "github.com/gorilla/websocket.Upgrader":
+			if check, ok := getStructFields(f, x)["CheckOrigin"]; ok && alwaysReturnsTrue(f, check) {
+				f.Reportf(check.Pos(), "WebSocket CheckOrigin always returns true, any site can open a connection with the user's cookies (cross-site WebSocket hijacking)");
+			}
+		}
+	}
+}
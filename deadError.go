@@ -0,0 +1,254 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ssa"
+)
+
+func init() {
+	register("deadError",
+		"this tests for errors that are assigned and then overwritten or never checked, and for shadowed errors",
+		deadErrorCheck,
+		funcDecl)
+}
+
+// isUsedValue checks if anything reads an SSA value.
+// a phi only counts as a use if the phi itself is read
+func isUsedValue(v ssa.Value, seen map[ssa.Value]bool) bool {
+	refs := v.Referrers();
+	if refs == nil {
+		return false;
+	}
+	for _, ref := range *refs {
+		switch r := ref.(type) {
+		case *ssa.DebugRef:
+			continue;
+		case *ssa.Phi:
+			if seen[r] {
+				continue;
+			}
+			seen[r] = true;
+			if isUsedValue(r, seen) {
+				return true;
+			}
+		default:
+			return true;
+		}
+	}
+	return false;
+}
+
+// getErrorValues returns the error results of a call along with their positions in the results.
+// results that are never extracted from a tuple aren't returned
+func getErrorValues(call *ssa.Call) map[int]ssa.Value {
+	values := make(map[int]ssa.Value);
+	tuple, ok := call.Type().(*types.Tuple);
+	if !ok {
+		if isErrorType(call.Type()) {
+			values[0] = call;
+		}
+		return values;
+	}
+	refs := call.Referrers();
+	if refs == nil {
+		return values;
+	}
+	for _, ref := range *refs {
+		if ext, ok := ref.(*ssa.Extract); ok && isErrorType(tuple.At(ext.Index).Type()) {
+			values[ext.Index] = ext;
+		}
+	}
+	return values;
+}
+
+// getAssignedIdent finds the variable the result of a call at a position is assigned to,
+// i.e. err in v, err := f(), and the statement assigning it
+func getAssignedIdent(f *File, pos token.Pos, index int) (*ast.Ident, ast.Node) {
+	path, _ := astutil.PathEnclosingInterval(f.file, pos, pos);
+	for i, n := range path {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || call.Lparen != pos || i + 1 >= len(path) {
+			continue;
+		}
+		var lhs []ast.Expr
+		var rhs []ast.Expr
+		switch stmt := path[i + 1].(type) {
+		case *ast.AssignStmt:
+			lhs, rhs = stmt.Lhs, stmt.Rhs;
+		case *ast.ValueSpec:
+			for _, name := range stmt.Names {
+				lhs = append(lhs, name);
+			}
+			rhs = stmt.Values;
+		default:
+			return nil, nil;
+		}
+		// a, err := f(), or x, err := g(), f()
+		if len(rhs) != 1 {
+			for j, x := range rhs {
+				if x == ast.Expr(call) {
+					index = j;
+				}
+			}
+		}
+		if index >= len(lhs) {
+			return nil, nil;
+		}
+		if id, ok := lhs[index].(*ast.Ident); ok && id.Name != "_" {
+			return id, path[i + 1];
+		}
+		return nil, nil;
+	}
+	return nil, nil;
+}
+
+// isReassigned checks if a variable is assigned again after a statement
+func isReassigned(f *File, body ast.Node, obj types.Object, after ast.Node) bool {
+	found := false;
+	ast.Inspect(body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.AssignStmt);
+		if !ok || found || stmt.Pos() < after.End() {
+			return !found;
+		}
+		for _, lhs := range stmt.Lhs {
+			if getObject(f, lhs) == obj {
+				found = true;
+			}
+		}
+		return !found;
+	});
+	return found;
+}
+
+// checkDeadErrors looks for errors that are assigned to a variable
+// and then overwritten or dropped without being read
+func checkDeadErrors(f *File, fun *ast.FuncDecl, fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call);
+			if !ok {
+				continue;
+			}
+			for index, v := range getErrorValues(call) {
+				if isUsedValue(v, make(map[ssa.Value]bool)) {
+					continue;
+				}
+				id, stmt := getAssignedIdent(f, call.Pos(), index);
+				if id == nil {
+					continue;
+				}
+				obj := getObject(f, id);
+				if obj != nil && isReassigned(f, fun.Body, obj, stmt) {
+					f.Reportf(call.Pos(), "error assigned to %s is overwritten before it is checked: %s", id.Name, getCallString(f, call.Pos()));
+				} else {
+					f.Reportf(call.Pos(), "error assigned to %s is never checked: %s", id.Name, getCallString(f, call.Pos()));
+				}
+			}
+		}
+	}
+	for _, anon := range fn.AnonFuncs {
+		checkDeadErrors(f, fun, anon);
+	}
+}
+
+// isReadAfter checks if the first use of a variable after a position reads it,
+// rather than assigning it a new value
+func isReadAfter(f *File, body ast.Node, obj types.Object, pos token.Pos) bool {
+	assigned := make(map[*ast.Ident]bool);
+	var first *ast.Ident
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok == token.ASSIGN || x.Tok == token.DEFINE {
+				for _, lhs := range x.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						assigned[id] = true;
+					}
+				}
+			}
+		case *ast.Ident:
+			if x.Pos() > pos && f.pkg.info.Uses[x] == obj && (first == nil || x.Pos() < first.Pos()) {
+				first = x;
+			}
+		}
+		return true;
+	});
+	return first != nil && !assigned[first];
+}
+
+// checkShadowedErrors looks for an error declared with := in an inner scope that hides
+// an error of the same name from an outer scope, where the outer error is read
+// once the inner scope ends. the inner assignment was probably meant for the
+// outer error, which never sees it, i.e.
+//
+//	var err error
+//	for _, x := range xs {
+//		v, err := parse(x)
+//		if err != nil {
+//			break
+//		}
+//	}
+//	return err
+//
+// the if err := f(); err != nil form is left alone as its scope is plainly the if statement
+func checkShadowedErrors(f *File, fun *ast.FuncDecl) {
+	inits := make(map[ast.Stmt]bool);
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.IfStmt:
+			inits[s.Init] = true;
+		case *ast.SwitchStmt:
+			inits[s.Init] = true;
+		case *ast.TypeSwitchStmt:
+			inits[s.Init] = true;
+		}
+		return true;
+	});
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false;
+		}
+		stmt, ok := n.(*ast.AssignStmt);
+		if !ok || stmt.Tok != token.DEFINE || inits[stmt] {
+			return true;
+		}
+		for _, lhs := range stmt.Lhs {
+			id, ok := lhs.(*ast.Ident);
+			if !ok {
+				continue;
+			}
+			inner, ok := f.pkg.info.Defs[id].(*types.Var);
+			if !ok || inner.Parent() == nil || inner.Parent().Parent() == nil || !isErrorType(inner.Type()) {
+				continue;
+			}
+			scope := inner.Parent();
+			_, outer := scope.Parent().LookupParent(id.Name, id.Pos());
+			if outer == nil || outer.Pos() < fun.Pos() || outer.Pos() > fun.End() || !isErrorType(outer.Type()) {
+				continue;
+			}
+			if isReadAfter(f, fun.Body, outer, scope.End()) {
+				f.Reportf(stmt.Pos(), "%s shadows the error declared at %s, which is checked after this scope without seeing it: %s", id.Name, f.loc(outer.Pos()), f.ASTString(stmt.Rhs[0]));
+			}
+		}
+		return true;
+	});
+}
+
+// deadErrorCheck looks for errors that are overwritten or never read,
+// which needs SSA, and for shadowed errors
+func deadErrorCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
+	}
+	if fn := getSSAFunc(f, fun); fn != nil {
+		checkDeadErrors(f, fun, fn);
+	}
+	checkShadowedErrors(f, fun);
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"os"
+)
+
+func init() {
+	register("deferClose",
+		"this tests for Close() deferred inside loops or on files opened for writing",
+		deferCloseCheck,
+		funcDecl,
+		funcLit)
+}
+
+// writeOpeners open files for writing. the value is the index of the
+// flag argument that decides it, or -1 if the file is always writable
+var writeOpeners = map[string]int{
+	"os.Create":		-1,
+	"os.CreateTemp":	-1,
+	"io/ioutil.TempFile":	-1,
+	"os.OpenFile":		1,
+}
+
+// writeFlags are the os.OpenFile flags that open a file for writing
+var writeFlags = map[string]bool{
+	"O_WRONLY":	true,
+	"O_RDWR":	true,
+	"O_APPEND":	true,
+	"O_CREATE":	true,
+	"O_TRUNC":	true,
+}
+
+// isWriteFlag checks if os.OpenFile flags open the file for writing.
+// constants are evaluated, otherwise the flag names are looked for
+func isWriteFlag(f *File, x ast.Expr) bool {
+	if v, ok := getConstInt(f, x); ok {
+		return v & int64(os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC) != 0;
+	}
+	found := false;
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.SelectorExpr:
+			found = found || writeFlags[e.Sel.Name];
+		case *ast.Ident:
+			found = found || writeFlags[e.Name];
+		}
+		return !found;
+	});
+	return found;
+}
+
+// opensForWriting checks if a call opens a file for writing
+func opensForWriting(f *File, call *ast.CallExpr) bool {
+	index, ok := writeOpeners[getCalleeName(f, call)];
+	if !ok {
+		return false;
+	}
+	return index < 0 || (index < len(call.Args) && isWriteFlag(f, call.Args[index]));
+}
+
+// getClosed returns what a call closes, i.e. f for f.Close(), or nil if it isn't a Close() call
+func getClosed(f *File, call *ast.CallExpr) ast.Expr {
+	sel, ok := call.Fun.(*ast.SelectorExpr);
+	if !ok || sel.Sel.Name != "Close" || len(call.Args) != 0 {
+		return nil;
+	}
+	// without type info any Close() will do
+	if t := f.pkg.info.TypeOf(sel.X); t != nil && !isCloser(t) {
+		return nil;
+	}
+	return sel.X;
+}
+
+// getDeferredCloses returns what a defer statement closes with the error thrown away.
+// that is defer f.Close(), or a deferred function literal calling f.Close()
+// without using the result
+func getDeferredCloses(f *File, stmt *ast.DeferStmt) []ast.Expr {
+	if x := getClosed(f, stmt.Call); x != nil {
+		return []ast.Expr{x};
+	}
+	lit, ok := stmt.Call.Fun.(*ast.FuncLit);
+	if !ok {
+		return nil;
+	}
+	var closed []ast.Expr
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			return false;
+		case *ast.ExprStmt:
+			if call, ok := s.X.(*ast.CallExpr); ok {
+				if x := getClosed(f, call); x != nil {
+					closed = append(closed, x);
+				}
+			}
+		case *ast.AssignStmt:
+			// _ = f.Close()
+			if len(s.Lhs) == 1 && len(s.Rhs) == 1 && getLhsName(s.Lhs[0]) == "_" {
+				if call, ok := s.Rhs[0].(*ast.CallExpr); ok {
+					if x := getClosed(f, call); x != nil {
+						closed = append(closed, x);
+					}
+				}
+			}
+		}
+		return true;
+	});
+	return closed;
+}
+
+// inLoop checks if any of the statements enclosing a node is a loop
+func inLoop(stack []ast.Node) bool {
+	for _, n := range stack {
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return true;
+		}
+	}
+	return false;
+}
+
+// deferCloseCheck looks for Close() deferred inside a loop, where nothing is closed
+// until the function returns and a long loop runs out of file descriptors,
+// and for Close() deferred on a file opened for writing, where the error
+// returned by Close() is the only sign buffered writes failed.
+// function literals are checked on their own as their defers run when they return
+func deferCloseCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		body = fun.Body;
+	}
+	if body == nil {
+		return;
+	}
+	writable := make(map[types.Object]bool);
+	var stack []ast.Node
+	ast.Inspect(body, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack) - 1];
+			return true;
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false;
+		}
+		stack = append(stack, n);
+		switch stmt := n.(type) {
+		case *ast.AssignStmt:
+			if len(stmt.Rhs) != 1 {
+				break;
+			}
+			if call, ok := stmt.Rhs[0].(*ast.CallExpr); ok && opensForWriting(f, call) {
+				if obj := getObject(f, stmt.Lhs[0]); obj != nil {
+					writable[obj] = true;
+				}
+			}
+		case *ast.DeferStmt:
+			closed := getDeferredCloses(f, stmt);
+			if len(closed) == 0 {
+				break;
+			}
+			if inLoop(stack) {
+				f.Reportf(stmt.Pos(), "defer Close() inside a loop, nothing is closed until the function returns: %s.Close()", f.ASTString(closed[0]));
+			}
+			for _, x := range closed {
+				if obj := getObject(f, x); obj != nil && writable[obj] {
+					f.Reportf(stmt.Pos(), "Close() error discarded on file opened for writing, failed writes may go unnoticed: %s.Close()", f.ASTString(x));
+				}
+			}
+		}
+		return true;
+	});
+}
@@ -13,44 +13,186 @@ func init() {
 		"this tests to see if any errors were ignored",
 		errorCheck,
 		assignStmt,
-		exprStmt)
+		exprStmt,
+		goStmt,
+		deferStmt)
 }
 
-// isPrint checks to see if the call is a print statement
-// this is used because people normally don't care about
-// print statement errors
-// todo: this could be more rigorous.  What if users invent
-// their own print statement that could be damaging if errors
-// are ignored? Consider also getting the full name and checking
-// if the print statement is from the fmt package.
-// Or maybe do exact matches for print statement methods in fmt.
-// i.e. Println;
-func isPrint(call *ast.CallExpr) bool {
-	name := getFuncName(call);
-	name = strings.ToLower(name);
-
-	return strings.Contains(name, "print");
+// neverFails are standard library functions and methods that return an error
+// that is always nil, or that is conventionally ignored like printing to stdout.
+// methods are named by the type of their receiver, so hash.Hash's Write is
+// (hash.Hash).Write though it comes from io.Writer
+var neverFails = map[string]bool{
+	"fmt.Print":				true,
+	"fmt.Printf":				true,
+	"fmt.Println":				true,
+	"(*bytes.Buffer).Write":		true,
+	"(*bytes.Buffer).WriteString":		true,
+	"(*bytes.Buffer).WriteByte":		true,
+	"(*bytes.Buffer).WriteRune":		true,
+	"(*strings.Builder).Write":		true,
+	"(*strings.Builder).WriteString":	true,
+	"(*strings.Builder).WriteByte":		true,
+	"(*strings.Builder).WriteRune":		true,
+	"(hash.Hash).Write":			true,
+	"(hash.Hash32).Write":			true,
+	"(hash.Hash64).Write":			true,
+	"math/rand.Read":			true,
+	"(*math/rand.Rand).Read":		true,
 }
 
-func returnsError(f *File, call *ast.CallExpr) int {
+// neverFailWriters are writers that fmt.Fprint and friends can't fail on
+var neverFailWriters = map[string]bool{
+	"*bytes.Buffer":	true,
+	"*strings.Builder":	true,
+	"hash.Hash":		true,
+}
+
+// fprintFuncs print to the writer given as their first argument
+var fprintFuncs = map[string]bool{
+	"fmt.Fprint":	true,
+	"fmt.Fprintf":	true,
+	"fmt.Fprintln":	true,
+}
+
+// isStdStream checks if an expression is os.Stdout or os.Stderr,
+// which are written to as freely as fmt.Println does
+func isStdStream(f *File, x ast.Expr) bool {
+	sel, ok := unparen(x).(*ast.SelectorExpr);
+	if !ok {
+		return false;
+	}
+	v, ok := f.pkg.info.Uses[sel.Sel].(*types.Var);
+	return ok && v.Pkg() != nil && v.Pkg().Path() == "os" && (v.Name() == "Stdout" || v.Name() == "Stderr");
+}
+
+// getErrorCalleeNames returns the names a call can be excluded by, the fully qualified name
+// of the function or method, and for methods the name with the receiver it was called on.
+// it also returns the package of the callee
+func getErrorCalleeNames(f *File, call *ast.CallExpr) ([]string, string) {
+	var names []string
+	pkgPath := "";
+	if fn := getCalledFunc(f, call); fn != nil {
+		names = append(names, fn.FullName());
+		if fn.Pkg() != nil {
+			pkgPath = fn.Pkg().Path();
+		}
+	} else if name := getCalleeName(f, call); name != "" {
+		names = append(names, name);
+		pkgPath = name[:strings.LastIndex(name, ".")];
+	}
+	if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok {
+		if selection, ok := f.pkg.info.Selections[sel]; ok && selection.Kind() == types.MethodVal {
+			recv := selection.Recv();
+			names = append(names, "(" + types.TypeString(recv, nil) + ")." + sel.Sel.Name);
+			if ptr, ok := recv.(*types.Pointer); ok {
+				recv = ptr.Elem();
+			}
+			if named, ok := recv.(*types.Named); ok && named.Obj().Pkg() != nil {
+				pkgPath = named.Obj().Pkg().Path();
+			}
+		}
+	}
+	return names, pkgPath;
+}
+
+// isExcludedCall checks if the errors a call returns can be ignored.
+// that is if it never fails, or it is excluded in the configuration
+func isExcludedCall(f *File, call *ast.CallExpr) bool {
+	names, pkgPath := getErrorCalleeNames(f, call);
+	for _, name := range names {
+		if neverFails[name] {
+			return true;
+		}
+		// fmt.Fprintf(&buf, ...) and fmt.Fprintln(os.Stderr, ...)
+		if fprintFuncs[name] && len(call.Args) > 0 {
+			if t := f.pkg.info.TypeOf(call.Args[0]); t != nil && neverFailWriters[t.String()] {
+				return true;
+			}
+			if isStdStream(f, call.Args[0]) {
+				return true;
+			}
+			// printing to anything else, i.e. an http.ResponseWriter, is conventionally
+			// left unchecked, so it is only reported with -verbose
+			if !*verbose {
+				return true;
+			}
+		}
+	}
+	// os.Stderr.WriteString(...)
+	if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok && isStdStream(f, sel.X) {
+		return true;
+	}
+	for _, exclude := range config.Errors.Exclude {
+		if exclude == pkgPath {
+			return true;
+		}
+		for _, name := range names {
+			if exclude == name {
+				return true;
+			}
+		}
+	}
+	return false;
+}
+
+// errorIface is the predeclared error interface
+var errorIface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// isErrorType checks if a type implements error,
+// so custom error types like *MyErr count as well as error itself
+func isErrorType(t types.Type) bool {
+	return t != nil && types.Implements(t, errorIface);
+}
+
+// unparen removes any parentheses around an expression
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		p, ok := x.(*ast.ParenExpr);
+		if !ok {
+			return x;
+		}
+		x = p.X;
+	}
+}
+
+// returnsError returns the positions of the results of a call that are errors.
+// calls to funcs held in variables, i.e. method values, are found by their type as well.
+// conversions are not calls and have no results
+func returnsError(f *File, call *ast.CallExpr) []int {
+	if tv, ok := f.pkg.info.Types[call.Fun]; ok && tv.IsType() {
+		return nil;
+	}
+	var indices []int
 	if typeValue := f.pkg.info.TypeOf(call); typeValue != nil {
-		switch t := typeValue.(type) {
-		case *types.Tuple:
+		if t, ok := typeValue.(*types.Tuple); ok {
 			for i := 0; i < t.Len(); i++ {
-				variable := t.At(i)
-				if variable != nil && variable.Type().String() == "error" {
-					return i;
+				if isErrorType(t.At(i).Type()) {
+					indices = append(indices, i);
 				}
 			}
-		case *types.Named:
-			if t.String() == "error"{
-				return 0;
-			}
-		}	
+		} else if isErrorType(typeValue) {
+			indices = append(indices, 0);
+		}
+	}
+	return indices;
+}
+
+// isBlank checks if an assignment target is _
+func isBlank(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident);
+	return ok && id.Name == "_";
+}
+
+// checkIgnoredCall reports a call whose results are all thrown away, i.e. a call on its own,
+// or in a go or defer statement, if it returns an error
+func checkIgnoredCall(f *File, stmt ast.Stmt, call *ast.CallExpr, format string) {
+	if len(returnsError(f, call)) == 0 || isExcludedCall(f, call) {
+		return;
 	}
-	return -1;
+	f.Reportf(stmt.Pos(), format, f.ASTString(call));
 }
- 
+
 // Possibly check if anything returns an error before running the test
 // however, this may take roughly the same amount of effort as
 // just running the test in the first place.
@@ -58,41 +200,41 @@ func returnsError(f *File, call *ast.CallExpr) int {
 func errorCheck(f *File, node ast.Node) {
 	switch stmt := node.(type) {
 	case *ast.AssignStmt:
-		for _, rhs := range stmt.Rhs {
-			if call, ok := rhs.(*ast.CallExpr); ok {
-				index := returnsError(f, call)
-				if index < 0 {
-					continue
-				}
-				// ignore print calls unless verbose
-				if isPrint(call) {
-					if(!(*verbose)) {
-						continue;
-					}
-				} 
-				lhs := stmt.Lhs[index]
-				if id, ok := lhs.(*ast.Ident); ok && id.Name == "_" {
-					// todo real reporting
-					re := f.ASTString(rhs);
-					le := f.ASTString(lhs);
-					f.Reportf(stmt.Pos(), "error ignored %s %s", le, re);
+		// a, _ := f() where f returns several results
+		if len(stmt.Rhs) == 1 && len(stmt.Lhs) > 1 {
+			call, ok := unparen(stmt.Rhs[0]).(*ast.CallExpr);
+			if !ok || isExcludedCall(f, call) {
+				return;
+			}
+			for _, index := range returnsError(f, call) {
+				if index < len(stmt.Lhs) && isBlank(stmt.Lhs[index]) {
+					f.Reportf(stmt.Pos(), "error ignored %s %s", f.ASTString(stmt.Lhs[index]), f.ASTString(call));
 				}
 			}
+			return;
 		}
-	case *ast.ExprStmt:
-		if expr, ok := stmt.X.(*ast.CallExpr); ok {
-			pos := returnsError(f, expr);
-			if pos >= 0 {
-				// todo: real reporting
-				// ignore print statements unless verbose
-				if isPrint(expr) {
-					if(!(*verbose)) {
-						return;
-					}
-				}
-				x := f.ASTString(expr);
-				f.Reportf(stmt.Pos(), "error ignored %s", x);
+		// _ = f(), or _, b = f(), g()
+		for i, rhs := range stmt.Rhs {
+			call, ok := unparen(rhs).(*ast.CallExpr);
+			if !ok || i >= len(stmt.Lhs) || !isBlank(stmt.Lhs[i]) {
+				continue;
+			}
+			if len(returnsError(f, call)) == 0 || isExcludedCall(f, call) {
+				continue;
 			}
+			f.Reportf(stmt.Pos(), "error ignored %s %s", f.ASTString(stmt.Lhs[i]), f.ASTString(call));
+		}
+	case *ast.ExprStmt:
+		if call, ok := unparen(stmt.X).(*ast.CallExpr); ok {
+			checkIgnoredCall(f, stmt, call, "error ignored %s");
+		}
+	case *ast.GoStmt:
+		checkIgnoredCall(f, stmt, stmt.Call, "error ignored in go statement %s");
+	case *ast.DeferStmt:
+		// deferred Close() is left to deferClose, which reports it where the error matters
+		if getClosed(f, stmt.Call) != nil {
+			return;
 		}
+		checkIgnoredCall(f, stmt, stmt.Call, "error ignored in deferred call %s");
 	}
 }
@@ -0,0 +1,652 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// git object types, numbered as they are in pack files
+const (
+	gitCommit	= 1
+	gitTree		= 2
+	gitBlob		= 3
+	gitTag		= 4
+	gitOfsDelta	= 6
+	gitRefDelta	= 7
+)
+
+var gitTypeNames = map[string]int{
+	"commit":	gitCommit,
+	"tree":		gitTree,
+	"blob":		gitBlob,
+	"tag":		gitTag,
+}
+
+// maxObjectCache limits how many delta bases each pack keeps around
+const maxObjectCache = 1024
+
+// gitRepo reads objects straight from a local repository's object store,
+// both loose and packed, without running git
+type gitRepo struct {
+	gitDir		string // where HEAD is
+	commonDir	string // where objects and refs are, the same as gitDir unless this is a worktree
+	packs		[]*gitPack
+}
+
+// gitObject is an object's type and contents
+type gitObject struct {
+	typ	int
+	data	[]byte
+}
+
+// gitPack is a pack file and its version 2 index
+type gitPack struct {
+	path	string
+	fanout	[256]uint32
+	hashes	[]byte
+	offsets	[]byte
+	large	[]byte
+	cache	map[int64]gitObject
+}
+
+// gitCommitInfo is the part of a commit the history scan needs
+type gitCommitInfo struct {
+	tree	string
+	parents	[]string
+	author	string
+}
+
+// gitTreeEntry is a file or directory in a tree
+type gitTreeEntry struct {
+	mode	string
+	name	string
+	hash	string
+}
+
+var errNoObject = errors.New("object not found")
+
+// openGitRepo opens the repository at path, which may be a work tree,
+// the .git directory itself or a bare repository
+func openGitRepo(path string) (*gitRepo, error) {
+	dir := filepath.Join(path, ".git");
+	info, err := os.Stat(dir);
+	switch {
+	case err == nil && info.IsDir():
+	case err == nil:
+		// worktrees and submodules have a .git file pointing to the real one
+		data, err := os.ReadFile(dir);
+		if err != nil {
+			return nil, err;
+		}
+		line := strings.TrimSpace(string(data));
+		if !strings.HasPrefix(line, "gitdir: ") {
+			return nil, fmt.Errorf("%s: unrecognised .git file", dir);
+		}
+		dir = strings.TrimPrefix(line, "gitdir: ");
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(path, dir);
+		}
+	default:
+		dir = path;
+	}
+	repo := &gitRepo{gitDir: dir, commonDir: dir};
+	if data, err := os.ReadFile(filepath.Join(dir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data));
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(dir, common);
+		}
+		repo.commonDir = common;
+	}
+	if _, err := os.Stat(filepath.Join(repo.commonDir, "objects")); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository", path);
+	}
+	idxs, _ := filepath.Glob(filepath.Join(repo.commonDir, "objects", "pack", "*.idx"));
+	for _, idx := range idxs {
+		pack, err := openGitPack(idx);
+		if err != nil {
+			return nil, err;
+		}
+		repo.packs = append(repo.packs, pack);
+	}
+	return repo, nil;
+}
+
+// minHashPrefix is the shortest abbreviated hash git accepts
+const minHashPrefix = 4
+
+// refPrefixes are tried in order to find a ref from a short name, the way git does,
+// i.e. main is refs/heads/main unless there is a tag called main
+var refPrefixes = []string{"refs/", "refs/tags/", "refs/heads/", "refs/remotes/"}
+
+var errNoRef = errors.New("ref not found")
+
+// resolveRef turns HEAD, a full or short ref name, or a full or abbreviated hash
+// into a commit hash. annotated tags are peeled to the commit they tag
+func (r *gitRepo) resolveRef(name string) (string, error) {
+	if !isValidRefName(name) {
+		return "", fmt.Errorf("bad revision %s", name);
+	}
+	hash, err := "", errNoRef;
+	if len(name) == 40 && isHashPrefix(name) {
+		hash, err = name, nil;
+	}
+	if err == errNoRef {
+		hash, err = r.findRef(name);
+	}
+	if err == errNoRef && len(name) >= minHashPrefix && isHashPrefix(name) {
+		hash, err = r.expandHash(name);
+	}
+	if err == errNoRef {
+		return "", fmt.Errorf("unknown revision %s", name);
+	}
+	if err != nil {
+		return "", err;
+	}
+	return r.peelToCommit(hash);
+}
+
+// findRef looks for a ref by its full name, or by a short name under refs/, refs/tags/,
+// refs/heads/ and refs/remotes/, or as a remote's default branch
+func (r *gitRepo) findRef(name string) (string, error) {
+	var candidates []string
+	// names outside refs/, like HEAD and ORIG_HEAD, are only looked up as they are
+	// so files like config in the git directory aren't taken for refs
+	if strings.HasPrefix(name, "refs/") || strings.ToUpper(name) == name {
+		candidates = append(candidates, name);
+	}
+	for _, prefix := range refPrefixes {
+		candidates = append(candidates, prefix + name);
+	}
+	candidates = append(candidates, "refs/remotes/" + name + "/HEAD");
+	for _, candidate := range candidates {
+		hash, err := r.readRef(candidate);
+		if err != errNoRef {
+			return hash, err;
+		}
+	}
+	return "", errNoRef;
+}
+
+// readRef reads a ref from its file or packed-refs, following symbolic refs
+func (r *gitRepo) readRef(name string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		if !isValidRefName(name) {
+			return "", fmt.Errorf("bad ref name %s", name);
+		}
+		// refs are shared between worktrees, HEAD and the like aren't
+		dir := r.gitDir;
+		if strings.HasPrefix(name, "refs/") {
+			dir = r.commonDir;
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)));
+		if err != nil {
+			return r.packedRef(name);
+		}
+		line := strings.TrimSpace(string(data));
+		if strings.HasPrefix(line, "ref: ") {
+			name = strings.TrimPrefix(line, "ref: ");
+			continue;
+		}
+		if len(line) != 40 || !isHashPrefix(line) {
+			return "", errNoRef;
+		}
+		return line, nil;
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs at %s", name);
+}
+
+// packedRef looks a ref up in the packed-refs file. a ^ line after
+// an annotated tag gives the commit it points at, which is returned instead
+func (r *gitRepo) packedRef(name string) (string, error) {
+	file, err := os.Open(filepath.Join(r.commonDir, "packed-refs"));
+	if err != nil {
+		return "", errNoRef;
+	}
+	defer file.Close();
+	hash := "";
+	scanner := bufio.NewScanner(file);
+	for scanner.Scan() {
+		line := scanner.Text();
+		if hash != "" {
+			if strings.HasPrefix(line, "^") {
+				return strings.TrimPrefix(line, "^"), nil;
+			}
+			return hash, nil;
+		}
+		fields := strings.Fields(line);
+		if len(fields) == 2 && fields[1] == name {
+			hash = fields[0];
+		}
+	}
+	if hash != "" {
+		return hash, nil;
+	}
+	return "", errNoRef;
+}
+
+// expandHash finds the only object whose hash starts with prefix, loose or packed
+func (r *gitRepo) expandHash(prefix string) (string, error) {
+	prefix = strings.ToLower(prefix);
+	found := make(map[string]bool);
+	entries, _ := os.ReadDir(filepath.Join(r.commonDir, "objects", prefix[:2]));
+	for _, entry := range entries {
+		if hash := prefix[:2] + entry.Name(); strings.HasPrefix(hash, prefix) {
+			found[hash] = true;
+		}
+	}
+	first, _ := hex.DecodeString(prefix[:2]);
+	for _, pack := range r.packs {
+		lo := 0;
+		if first[0] > 0 {
+			lo = int(pack.fanout[first[0] - 1]);
+		}
+		for i := lo; i < int(pack.fanout[first[0]]); i++ {
+			if hash := hex.EncodeToString(pack.hashes[i * 20:i * 20 + 20]); strings.HasPrefix(hash, prefix) {
+				found[hash] = true;
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", errNoRef;
+	case 1:
+		for hash := range found {
+			return hash, nil;
+		}
+	}
+	return "", fmt.Errorf("short hash %s is ambiguous", prefix);
+}
+
+// peelToCommit follows annotated tags to the commit they point at
+func (r *gitRepo) peelToCommit(hash string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		obj, err := r.readObject(hash);
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", hash, err);
+		}
+		switch obj.typ {
+		case gitCommit:
+			return hash, nil;
+		case gitTag:
+			// "object hash" is the first header of a tag
+			line := string(obj.data);
+			if i := strings.IndexByte(line, '\n'); i >= 0 {
+				line = line[:i];
+			}
+			if !strings.HasPrefix(line, "object ") {
+				return "", fmt.Errorf("corrupt tag %s", hash);
+			}
+			hash = strings.TrimPrefix(line, "object ");
+		default:
+			return "", fmt.Errorf("%s is not a commit", hash);
+		}
+	}
+	return "", fmt.Errorf("too many levels of tags at %s", hash);
+}
+
+// isHashPrefix checks if a string is made of the lower case hex digits of an object hash
+func isHashPrefix(s string) bool {
+	return len(s) <= 40 && s != "" && strings.Trim(s, "0123456789abcdef") == "";
+}
+
+// isValidRefName checks a ref name follows git's rules for ref names,
+// which keeps it from reaching outside the git directory, i.e. ../../etc/passwd
+func isValidRefName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "//") ||
+		strings.Contains(name, "@{") {
+		return false;
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false;
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false;
+		}
+	}
+	return true;
+}
+
+// readObject returns the object with the given hash, loose or packed
+func (r *gitRepo) readObject(hash string) (gitObject, error) {
+	obj, err := r.readLooseObject(hash);
+	if err != errNoObject {
+		return obj, err;
+	}
+	raw, err := hex.DecodeString(hash);
+	if err != nil || len(raw) != 20 {
+		return gitObject{}, fmt.Errorf("bad object name %s", hash);
+	}
+	for _, pack := range r.packs {
+		if offset, ok := pack.find(raw); ok {
+			return pack.readAt(r, offset);
+		}
+	}
+	return gitObject{}, errNoObject;
+}
+
+// readLooseObject reads an object from objects/xx/xxxx...
+func (r *gitRepo) readLooseObject(hash string) (gitObject, error) {
+	if len(hash) != 40 {
+		return gitObject{}, fmt.Errorf("bad object name %s", hash);
+	}
+	file, err := os.Open(filepath.Join(r.commonDir, "objects", hash[:2], hash[2:]));
+	if err != nil {
+		return gitObject{}, errNoObject;
+	}
+	defer file.Close();
+	zr, err := zlib.NewReader(file);
+	if err != nil {
+		return gitObject{}, err;
+	}
+	defer zr.Close();
+	data, err := io.ReadAll(zr);
+	if err != nil {
+		return gitObject{}, err;
+	}
+	// "type size\0contents"
+	nul := bytes.IndexByte(data, 0);
+	if nul < 0 {
+		return gitObject{}, fmt.Errorf("corrupt object %s", hash);
+	}
+	header := strings.Fields(string(data[:nul]));
+	if len(header) != 2 {
+		return gitObject{}, fmt.Errorf("corrupt object %s", hash);
+	}
+	typ, ok := gitTypeNames[header[0]];
+	if !ok {
+		return gitObject{}, fmt.Errorf("unknown object type %s", header[0]);
+	}
+	return gitObject{typ: typ, data: data[nul + 1:]}, nil;
+}
+
+// openGitPack reads a version 2 pack index
+func openGitPack(idxPath string) (*gitPack, error) {
+	data, err := os.ReadFile(idxPath);
+	if err != nil {
+		return nil, err;
+	}
+	if len(data) < 8 + 256 * 4 || !bytes.Equal(data[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(data[4:8]) != 2 {
+		return nil, fmt.Errorf("%s: unsupported pack index version", idxPath);
+	}
+	pack := &gitPack{
+		path:	strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		cache:	make(map[int64]gitObject),
+	};
+	for i := range pack.fanout {
+		pack.fanout[i] = binary.BigEndian.Uint32(data[8 + i * 4:]);
+	}
+	n := int(pack.fanout[255]);
+	pos := 8 + 256 * 4;
+	if len(data) < pos + n * 28 {
+		return nil, fmt.Errorf("%s: truncated pack index", idxPath);
+	}
+	pack.hashes = data[pos:pos + n * 20];
+	pos += n * 20;
+	// skip the CRCs
+	pos += n * 4;
+	pack.offsets = data[pos:pos + n * 4];
+	pos += n * 4;
+	pack.large = data[pos:];
+	return pack, nil;
+}
+
+// find returns the offset of an object in the pack
+func (p *gitPack) find(hash []byte) (int64, bool) {
+	lo := 0;
+	if hash[0] > 0 {
+		lo = int(p.fanout[hash[0] - 1]);
+	}
+	hi := int(p.fanout[hash[0]]);
+	for lo < hi {
+		mid := (lo + hi) / 2;
+		switch bytes.Compare(p.hashes[mid * 20:mid * 20 + 20], hash) {
+		case 0:
+			offset := int64(binary.BigEndian.Uint32(p.offsets[mid * 4:]));
+			// the top bit means the offset is in the table of large offsets
+			if offset & 0x80000000 != 0 {
+				i := int(offset & 0x7fffffff);
+				offset = int64(binary.BigEndian.Uint64(p.large[i * 8:]));
+			}
+			return offset, true;
+		case -1:
+			lo = mid + 1;
+		default:
+			hi = mid;
+		}
+	}
+	return 0, false;
+}
+
+// readAt reads the object at an offset in the pack, applying any deltas
+func (p *gitPack) readAt(r *gitRepo, offset int64) (gitObject, error) {
+	if obj, ok := p.cache[offset]; ok {
+		return obj, nil;
+	}
+	file, err := os.Open(p.path);
+	if err != nil {
+		return gitObject{}, err;
+	}
+	defer file.Close();
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return gitObject{}, err;
+	}
+	br := bufio.NewReader(file);
+	// the header is the type and a variable length size
+	c, err := br.ReadByte();
+	if err != nil {
+		return gitObject{}, err;
+	}
+	typ := int(c >> 4) & 7;
+	for c & 0x80 != 0 {
+		if c, err = br.ReadByte(); err != nil {
+			return gitObject{}, err;
+		}
+	}
+	var base gitObject
+	switch typ {
+	case gitOfsDelta:
+		// the base is a negative offset, stored big endian with an odd encoding
+		c, err := br.ReadByte();
+		if err != nil {
+			return gitObject{}, err;
+		}
+		rel := int64(c & 0x7f);
+		for c & 0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return gitObject{}, err;
+			}
+			rel = ((rel + 1) << 7) | int64(c & 0x7f);
+		}
+		if base, err = p.readAt(r, offset - rel); err != nil {
+			return gitObject{}, err;
+		}
+	case gitRefDelta:
+		raw := make([]byte, 20);
+		if _, err := io.ReadFull(br, raw); err != nil {
+			return gitObject{}, err;
+		}
+		if base, err = r.readObject(hex.EncodeToString(raw)); err != nil {
+			return gitObject{}, err;
+		}
+	}
+	zr, err := zlib.NewReader(br);
+	if err != nil {
+		return gitObject{}, err;
+	}
+	defer zr.Close();
+	data, err := io.ReadAll(zr);
+	if err != nil {
+		return gitObject{}, err;
+	}
+	obj := gitObject{typ: typ, data: data};
+	if typ == gitOfsDelta || typ == gitRefDelta {
+		patched, err := applyDelta(base.data, data);
+		if err != nil {
+			return gitObject{}, fmt.Errorf("%s at %d: %v", p.path, offset, err);
+		}
+		obj = gitObject{typ: base.typ, data: patched};
+	}
+	if len(p.cache) >= maxObjectCache {
+		p.cache = make(map[int64]gitObject);
+	}
+	p.cache[offset] = obj;
+	return obj, nil;
+}
+
+// readDeltaSize reads one of the little endian variable length sizes at the start of a delta
+func readDeltaSize(delta []byte, pos int) (int, int) {
+	size, shift := 0, uint(0);
+	for pos < len(delta) {
+		c := delta[pos];
+		pos++;
+		size |= int(c & 0x7f) << shift;
+		shift += 7;
+		if c & 0x80 == 0 {
+			break;
+		}
+	}
+	return size, pos;
+}
+
+// applyDelta rebuilds an object from its base and a delta of copy and insert instructions
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, pos := readDeltaSize(delta, 0);
+	if srcSize != len(base) {
+		return nil, errors.New("delta base size mismatch");
+	}
+	dstSize, pos := readDeltaSize(delta, pos);
+	out := make([]byte, 0, dstSize);
+	for pos < len(delta) {
+		op := delta[pos];
+		pos++;
+		if op & 0x80 == 0 {
+			// insert the next op bytes
+			if op == 0 || pos + int(op) > len(delta) {
+				return nil, errors.New("bad delta insert");
+			}
+			out = append(out, delta[pos:pos + int(op)]...);
+			pos += int(op);
+			continue;
+		}
+		// copy from the base, the low bits say which offset and size bytes follow
+		var offset, size int
+		for i := uint(0); i < 4; i++ {
+			if op & (1 << i) != 0 {
+				if pos >= len(delta) {
+					return nil, errors.New("truncated delta");
+				}
+				offset |= int(delta[pos]) << (8 * i);
+				pos++;
+			}
+		}
+		for i := uint(0); i < 3; i++ {
+			if op & (0x10 << i) != 0 {
+				if pos >= len(delta) {
+					return nil, errors.New("truncated delta");
+				}
+				size |= int(delta[pos]) << (8 * i);
+				pos++;
+			}
+		}
+		if size == 0 {
+			size = 0x10000;
+		}
+		if offset + size > len(base) {
+			return nil, errors.New("delta copy out of range");
+		}
+		out = append(out, base[offset:offset + size]...);
+	}
+	if len(out) != dstSize {
+		return nil, errors.New("delta result size mismatch");
+	}
+	return out, nil;
+}
+
+// readCommit reads the tree, parents and author of a commit
+func (r *gitRepo) readCommit(hash string) (gitCommitInfo, error) {
+	var info gitCommitInfo
+	obj, err := r.readObject(hash);
+	if err != nil {
+		return info, err;
+	}
+	if obj.typ != gitCommit {
+		return info, fmt.Errorf("%s is not a commit", hash);
+	}
+	for _, line := range strings.Split(string(obj.data), "\n") {
+		// the headers end at the first blank line
+		if line == "" {
+			break;
+		}
+		switch {
+		case strings.HasPrefix(line, "tree "):
+			info.tree = strings.TrimPrefix(line, "tree ");
+		case strings.HasPrefix(line, "parent "):
+			info.parents = append(info.parents, strings.TrimPrefix(line, "parent "));
+		case strings.HasPrefix(line, "author "):
+			// "author Name <email> 1234567890 +0000", keep the name and email
+			author := strings.TrimPrefix(line, "author ");
+			if i := strings.LastIndex(author, ">"); i >= 0 {
+				author = author[:i + 1];
+			}
+			info.author = author;
+		}
+	}
+	return info, nil;
+}
+
+// readTree reads the entries of a tree
+func (r *gitRepo) readTree(hash string) ([]gitTreeEntry, error) {
+	obj, err := r.readObject(hash);
+	if err != nil {
+		return nil, err;
+	}
+	if obj.typ != gitTree {
+		return nil, fmt.Errorf("%s is not a tree", hash);
+	}
+	var entries []gitTreeEntry
+	// each entry is "mode name\0" then a 20 byte hash
+	data := obj.data;
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ');
+		nul := bytes.IndexByte(data, 0);
+		if sp < 0 || nul < sp || len(data) < nul + 21 {
+			return nil, fmt.Errorf("corrupt tree %s", hash);
+		}
+		entries = append(entries, gitTreeEntry{
+			mode:	string(data[:sp]),
+			name:	string(data[sp + 1:nul]),
+			hash:	hex.EncodeToString(data[nul + 1:nul + 21]),
+		});
+		data = data[nul + 21:];
+	}
+	return entries, nil;
+}
+
+// isGitDir checks if a tree entry mode is a directory
+func isGitDir(mode string) bool {
+	m, err := strconv.ParseUint(mode, 8, 32);
+	return err == nil && m & 0170000 == 0040000;
+}
+
+// isGitFile checks if a tree entry mode is a regular file, not a link or submodule
+func isGitFile(mode string) bool {
+	m, err := strconv.ParseUint(mode, 8, 32);
+	return err == nil && m & 0170000 == 0100000;
+}
@@ -0,0 +1,357 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// historyFinding is a secret added to a file by a commit
+type historyFinding struct {
+	Fingerprint	string	`json:"fingerprint"`
+	Desc		string	`json:"description"`
+	Commit		string	`json:"commit"`
+	Author		string	`json:"author"`
+	Path		string	`json:"path"`
+	Line		int	`json:"line"`
+
+	secret	string // printable form of the secret, never written to the baseline
+}
+
+// historyBaseline is the file of secrets that have been looked at,
+// i.e. rotated, and shouldn't be reported again
+type historyBaseline struct {
+	Secrets []historyFinding `json:"secrets"`
+}
+
+// fingerprintSecret identifies a secret without storing it
+func fingerprintSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret));
+	return hex.EncodeToString(sum[:]);
+}
+
+// loadBaseline reads a baseline file, a missing file is an empty baseline
+func loadBaseline(name string) (historyBaseline, error) {
+	var baseline historyBaseline
+	if name == "" {
+		return baseline, nil;
+	}
+	data, err := os.ReadFile(name);
+	if os.IsNotExist(err) {
+		return baseline, nil;
+	}
+	if err != nil {
+		return baseline, err;
+	}
+	err = json.Unmarshal(data, &baseline);
+	return baseline, err;
+}
+
+// runHistory is the history command. It scans the lines added by every commit
+// reachable from a starting commit for credentials.
+//
+//	glasgo history [-rev ref] [-baseline file] [-update-baseline] [-max-count n] [repository]
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError);
+	rev := fs.String("rev", "HEAD", "commit or ref to start from");
+	baselineFile := fs.String("baseline", "", "JSON file of acknowledged secrets that are not reported");
+	updateBaseline := fs.Bool("update-baseline", false, "add every secret found to the baseline file instead of reporting them");
+	maxCount := fs.Int("max-count", 0, "stop after this many commits, 0 for no limit");
+	fs.Parse(args);
+
+	repoPath := ".";
+	if fs.NArg() > 0 {
+		repoPath = fs.Arg(0);
+	}
+	if *updateBaseline && *baselineFile == "" {
+		warnf("history: -update-baseline needs -baseline");
+		os.Exit(1);
+	}
+	repo, err := openGitRepo(repoPath);
+	if err != nil {
+		warnf("history: %s", err);
+		os.Exit(1);
+	}
+	start, err := repo.resolveRef(*rev);
+	if err != nil {
+		warnf("history: %s", err);
+		os.Exit(1);
+	}
+	baseline, err := loadBaseline(*baselineFile);
+	if err != nil {
+		warnf("history: error reading baseline %s: %s", *baselineFile, err);
+		os.Exit(1);
+	}
+	known := make(map[string]bool);
+	for _, s := range baseline.Secrets {
+		known[s.Fingerprint] = true;
+	}
+
+	var found []historyFinding
+	err = walkHistory(repo, start, *maxCount, func(f historyFinding) {
+		if known[f.Fingerprint] {
+			return;
+		}
+		found = append(found, f);
+		if !*updateBaseline {
+			fmt.Fprintf(os.Stderr, "\t* %s %s %s:%d %s: %s \n", f.Commit[:12], f.Author, f.Path, f.Line, f.Desc, f.secret);
+		}
+	});
+	if err != nil {
+		warnf("history: %s", err);
+		exitCode = 1;
+	}
+	if !*updateBaseline {
+		return;
+	}
+	// one entry per secret is enough to acknowledge it everywhere
+	for _, f := range found {
+		if !known[f.Fingerprint] {
+			known[f.Fingerprint] = true;
+			baseline.Secrets = append(baseline.Secrets, f);
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf);
+	enc.SetEscapeHTML(false);
+	enc.SetIndent("", "\t");
+	err = enc.Encode(baseline);
+	if err == nil {
+		err = os.WriteFile(*baselineFile, buf.Bytes(), 0644);
+	}
+	if err != nil {
+		warnf("history: error writing baseline %s: %s", *baselineFile, err);
+		os.Exit(1);
+	}
+	fmt.Printf("%d secrets in baseline %s\n", len(baseline.Secrets), *baselineFile);
+}
+
+// walkHistory goes through the commits reachable from start and calls report
+// for each secret on a line the commit added. merges are followed but not
+// scanned, their changes are already in the commits being merged.
+func walkHistory(repo *gitRepo, start string, maxCount int, report func(historyFinding)) error {
+	seen := map[string]bool{start: true};
+	stack := []string{start};
+	for count := 0; len(stack) > 0; count++ {
+		if maxCount > 0 && count >= maxCount {
+			break;
+		}
+		hash := stack[len(stack) - 1];
+		stack = stack[:len(stack) - 1];
+		commit, err := repo.readCommit(hash);
+		if err != nil {
+			// shallow clones are missing their oldest parents
+			if err == errNoObject {
+				continue;
+			}
+			return fmt.Errorf("commit %s: %v", hash, err);
+		}
+		for _, parent := range commit.parents {
+			if !seen[parent] {
+				seen[parent] = true;
+				stack = append(stack, parent);
+			}
+		}
+		if len(commit.parents) > 1 {
+			continue;
+		}
+		parentTree := "";
+		if len(commit.parents) == 1 {
+			if parent, err := repo.readCommit(commit.parents[0]); err == nil {
+				parentTree = parent.tree;
+			}
+		}
+		err = diffTrees(repo, parentTree, commit.tree, "", func(name, oldBlob, newBlob string) error {
+			return scanBlobChange(repo, hash, commit.author, name, oldBlob, newBlob, report);
+		});
+		if err != nil {
+			return fmt.Errorf("commit %s: %v", hash, err);
+		}
+	}
+	return nil;
+}
+
+// diffTrees calls changed for every file that is new or different in newTree.
+// subtrees with the same hash on both sides are skipped without being read.
+// oldTree is empty for a commit with no parent.
+func diffTrees(repo *gitRepo, oldTree, newTree, prefix string, changed func(name, oldBlob, newBlob string) error) error {
+	if oldTree == newTree {
+		return nil;
+	}
+	newEntries, err := repo.readTree(newTree);
+	if err != nil {
+		return err;
+	}
+	old := make(map[string]gitTreeEntry);
+	if oldTree != "" {
+		oldEntries, err := repo.readTree(oldTree);
+		if err != nil {
+			return err;
+		}
+		for _, e := range oldEntries {
+			old[e.name] = e;
+		}
+	}
+	for _, e := range newEntries {
+		name := path.Join(prefix, e.name);
+		prev, existed := old[e.name];
+		if existed && prev.hash == e.hash {
+			continue;
+		}
+		switch {
+		case isGitDir(e.mode):
+			prevTree := "";
+			if existed && isGitDir(prev.mode) {
+				prevTree = prev.hash;
+			}
+			err = diffTrees(repo, prevTree, e.hash, name, changed);
+		case isGitFile(e.mode):
+			prevBlob := "";
+			if existed && isGitFile(prev.mode) {
+				prevBlob = prev.hash;
+			}
+			err = changed(name, prevBlob, e.hash);
+		}
+		if err != nil {
+			return err;
+		}
+	}
+	return nil;
+}
+
+// readTextBlob reads a blob, leaving out anything binary or too big to be worth scanning
+func readTextBlob(repo *gitRepo, hash string) (string, bool) {
+	obj, err := repo.readObject(hash);
+	if err != nil || obj.typ != gitBlob || len(obj.data) > maxSecretFileSize || bytes.IndexByte(obj.data, 0) >= 0 {
+		return "", false;
+	}
+	return string(obj.data), true;
+}
+
+// getAddedLines works out which lines of the new contents were added.
+// lines are matched up by content, so moved lines don't count as added.
+func getAddedLines(oldContent, newContent string) map[int]bool {
+	remaining := make(map[string]int);
+	if oldContent != "" {
+		for _, line := range strings.Split(oldContent, "\n") {
+			remaining[line]++;
+		}
+	}
+	added := make(map[int]bool);
+	for i, line := range strings.Split(newContent, "\n") {
+		if remaining[line] > 0 {
+			remaining[line]--;
+			continue;
+		}
+		added[i + 1] = true;
+	}
+	return added;
+}
+
+// scanBlobChange runs the hardcoded credential detectors over the lines
+// a commit added to a file
+func scanBlobChange(repo *gitRepo, commit, author, name, oldBlob, newBlob string, report func(historyFinding)) error {
+	if matchesPath(name, config.Hardcoded.ExcludePaths) {
+		return nil;
+	}
+	content, ok := readTextBlob(repo, newBlob);
+	if !ok {
+		return nil;
+	}
+	oldContent := "";
+	if oldBlob != "" {
+		oldContent, _ = readTextBlob(repo, oldBlob);
+	}
+	added := getAddedLines(oldContent, content);
+	if len(added) == 0 {
+		return nil;
+	}
+	var found []historyFinding
+	add := func(line int, desc, secret, printable string) {
+		found = append(found, historyFinding{
+			Fingerprint:	fingerprintSecret(secret),
+			Desc:		desc,
+			Commit:		commit,
+			Author:		author,
+			Path:		name,
+			Line:		line,
+			secret:		printable,
+		});
+	};
+	// private keys span lines so look through the whole file
+	for _, m := range findSecrets(content) {
+		line := strings.Count(content[:m.offset], "\n") + 1;
+		if added[line] && !isAllowedLit(m.value) {
+			add(line, "Hardcoded " + m.name + " found", m.value, m.String());
+		}
+	}
+	if strings.HasSuffix(name, ".go") {
+		scanGoBlob(name, content, func(line int, desc, secret string) {
+			if added[line] {
+				add(line, desc, secret, secret);
+			}
+		});
+	} else if kind := getSecretFileType(name); kind != "" {
+		f := &File{name: name};
+		for i, line := range strings.Split(content, "\n") {
+			if !added[i + 1] {
+				continue;
+			}
+			for _, pair := range getKeyValues(kind, strings.TrimRight(line, "\r")) {
+				if desc, secret := checkKeyValue(f, pair[0], pair[1]); desc != "" {
+					add(i + 1, desc, secret, secret);
+				}
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Line < found[j].Line });
+	for _, f := range found {
+		report(f);
+	}
+	return nil;
+}
+
+// scanGoBlob parses a Go file and scores its literals with the names they are
+// given the same way the hardcoded test does, calling found with the line of each.
+// there is no type information, and files that don't parse are scanned as far as they go
+func scanGoBlob(name, content string, found func(line int, desc, secret string)) {
+	fset := token.NewFileSet();
+	file, _ := parser.ParseFile(fset, name, content, 0);
+	if file == nil {
+		return;
+	}
+	f := &File{
+		pkg:	&Package{info: &types.Info{}},
+		fset:	fset,
+		name:	name,
+		file:	file,
+	};
+	ast.Inspect(file, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				if password, ok := getURLPassword(s); ok {
+					found(fset.Position(lit.Pos()).Line, "Hardcoded password in URL", password);
+				}
+			}
+		}
+		for _, c := range findCredentials(f, n) {
+			found(fset.Position(c.pos).Line, c.desc, c.secret);
+		}
+		return true;
+	});
+}
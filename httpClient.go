@@ -0,0 +1,191 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func init() {
+	register("httpClient",
+		"this tests for HTTP clients without timeouts and redirect policies that leak credentials",
+		httpClientCheck,
+		callExpr,
+		compositeLit)
+}
+
+// defaultClientCalls use http.DefaultClient, which has no timeout
+var defaultClientCalls = map[string]bool{
+	"net/http.Get":		true,
+	"net/http.Head":	true,
+	"net/http.Post":	true,
+	"net/http.PostForm":	true,
+}
+
+// credentialHeaders carry credentials that shouldn't follow a redirect to another host
+var credentialHeaders = map[string]bool{
+	"authorization":	true,
+	"proxy-authorization":	true,
+	"cookie":		true,
+	"x-api-key":		true,
+	"x-auth-token":		true,
+}
+
+// isDefaultClient checks if an expression is http.DefaultClient
+func isDefaultClient(f *File, x ast.Expr) bool {
+	sel, ok := unparen(x).(*ast.SelectorExpr);
+	if !ok {
+		return false;
+	}
+	v, ok := f.pkg.info.Uses[sel.Sel].(*types.Var);
+	return ok && v.Pkg() != nil && v.Pkg().Path() == "net/http" && v.Name() == "DefaultClient";
+}
+
+// hasDialTimeout checks the dialer a Transport uses has a timeout.
+// (&net.Dialer{Timeout: t}).DialContext is checked, any other dial function is trusted
+func hasDialTimeout(f *File, fields map[string]ast.Expr) bool {
+	dial, ok := fields["DialContext"];
+	if !ok {
+		dial, ok = fields["Dial"];
+	}
+	if !ok || isNil(f, dial) {
+		return false;
+	}
+	sel, ok := unparen(dial).(*ast.SelectorExpr);
+	if !ok {
+		return true;
+	}
+	x := unparen(sel.X);
+	if u, ok := x.(*ast.UnaryExpr); ok {
+		x = u.X;
+	}
+	lit, ok := x.(*ast.CompositeLit);
+	if !ok || !isNamedType(f.pkg.info.TypeOf(lit), "net", "Dialer") {
+		return true;
+	}
+	return !isUnset(f, getStructFields(f, lit), "Timeout");
+}
+
+// getFuncBody returns the body of a function literal, or of a function declared in the package
+func getFuncBody(f *File, x ast.Expr) *ast.BlockStmt {
+	switch v := unparen(x).(type) {
+	case *ast.FuncLit:
+		return v.Body;
+	case *ast.Ident:
+		if fn, ok := f.pkg.info.Uses[v].(*types.Func); ok {
+			if decl := getFuncDecl(f, fn); decl != nil {
+				return decl.Body;
+			}
+		}
+	}
+	return nil;
+}
+
+// forwardsCredentials checks if a CheckRedirect callback puts credentials on the
+// redirected request without ever looking at the host it is going to
+func forwardsCredentials(f *File, body *ast.BlockStmt) bool {
+	forwards := false;
+	checksHost := false;
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			if x.Sel.Name == "Host" || x.Sel.Name == "Hostname" {
+				checksHost = true;
+			}
+		case *ast.CallExpr:
+			switch getCalleeName(f, x) {
+			case "(net/http.Header).Set", "(net/http.Header).Add":
+				if key, ok := getConstString(f, x.Args[0]); ok && credentialHeaders[strings.ToLower(key)] {
+					forwards = true;
+				}
+			case "(*net/http.Request).SetBasicAuth", "(*net/http.Request).AddCookie":
+				forwards = true;
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range x.Lhs {
+				t := f.pkg.info.TypeOf(lhs);
+				// req.Header = via[0].Header, or req.Header[k] = v copying every header
+				if isNamedType(t, "net/http", "Header") {
+					forwards = true;
+				}
+				if idx, ok := lhs.(*ast.IndexExpr); ok && isNamedType(f.pkg.info.TypeOf(idx.X), "net/http", "Header") {
+					if key, ok := getConstString(f, idx.Index); !ok || credentialHeaders[strings.ToLower(key)] {
+						forwards = true;
+					}
+				}
+			}
+		}
+		return true;
+	});
+	return forwards && !checksHost;
+}
+
+// hasClientTimeout checks if a Transport literal is given to an http.Client literal
+// that sets a Timeout, i.e. http.Client{Timeout: time.Minute, Transport: &http.Transport{}},
+// which bounds dialing and the handshake along with everything else
+func hasClientTimeout(f *File, lit *ast.CompositeLit) bool {
+	path, _ := astutil.PathEnclosingInterval(f.file, lit.Pos(), lit.End());
+	for _, n := range path[1:] {
+		switch x := n.(type) {
+		case *ast.UnaryExpr, *ast.ParenExpr, *ast.KeyValueExpr:
+			continue;
+		case *ast.CompositeLit:
+			if isNamedType(f.pkg.info.TypeOf(x), "net/http", "Client") {
+				return !isUnset(f, getStructFields(f, x), "Timeout");
+			}
+		}
+		break;
+	}
+	return false;
+}
+
+// httpClientCheck looks for outbound HTTP requests that can hang forever,
+// through http.DefaultClient, an http.Client without a Timeout or an http.Transport
+// without dial and TLS handshake timeouts, and for CheckRedirect callbacks
+// that send credentials on to whatever host a redirect points at
+func httpClientCheck(f *File, node ast.Node) {
+	switch x := node.(type) {
+	case *ast.CallExpr:
+		name := getCalleeName(f, x);
+		if defaultClientCalls[name] {
+			f.Reportf(x.Pos(), "%s uses http.DefaultClient, which has no timeout, a hung server blocks forever: %s", strings.TrimPrefix(name, "net/"), f.ASTString(x));
+			return;
+		}
+		if sel, ok := unparen(x.Fun).(*ast.SelectorExpr); ok && isDefaultClient(f, sel.X) {
+			f.Reportf(x.Pos(), "http.DefaultClient has no timeout, a hung server blocks forever: %s", f.ASTString(x));
+		}
+	case *ast.CompositeLit:
+		t := f.pkg.info.TypeOf(x);
+		switch {
+		case isNamedType(t, "net/http", "Client"):
+			fields := getStructFields(f, x);
+			if isUnset(f, fields, "Timeout") {
+				f.Reportf(x.Pos(), "http.Client without Timeout, a hung server blocks forever");
+			}
+			if redirect, ok := fields["CheckRedirect"]; ok {
+				if body := getFuncBody(f, redirect); body != nil && forwardsCredentials(f, body) {
+					f.Reportf(redirect.Pos(), "CheckRedirect copies credentials onto redirects without checking the host, they are sent to whatever host the redirect points at");
+				}
+			}
+		case isNamedType(t, "net/http", "Transport"):
+			if hasClientTimeout(f, x) {
+				return;
+			}
+			fields := getStructFields(f, x);
+			var missing []string
+			if !hasDialTimeout(f, fields) {
+				missing = append(missing, "a dial timeout");
+			}
+			if isUnset(f, fields, "TLSHandshakeTimeout") {
+				missing = append(missing, "TLSHandshakeTimeout");
+			}
+			if len(missing) > 0 {
+				f.Reportf(x.Pos(), "http.Transport without %s, connections can hang forever", strings.Join(missing, " or "));
+			}
+		}
+	}
+}
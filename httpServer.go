@@ -0,0 +1,73 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"go/ast"
+	"strings"
+)
+
+func init() {
+	register("httpServer",
+		"this tests for HTTP servers without timeouts",
+		httpServerCheck,
+		callExpr,
+		compositeLit)
+}
+
+// defaultServerCalls serve HTTP with a server that has no timeouts
+var defaultServerCalls = map[string]bool{
+	"net/http.ListenAndServe":	true,
+	"net/http.ListenAndServeTLS":	true,
+	"net/http.Serve":		true,
+	"net/http.ServeTLS":		true,
+}
+
+// isUnset checks if a field is left out or set to zero
+func isUnset(f *File, fields map[string]ast.Expr, name string) bool {
+	x, ok := fields[name];
+	if !ok {
+		return true;
+	}
+	val, ok := getConstInt(f, x);
+	return ok && val == 0;
+}
+
+// getMissingServerFields returns the limits an http.Server doesn't set.
+// ReadTimeout covers reading the headers and is used for IdleTimeout if that is unset
+func getMissingServerFields(f *File, fields map[string]ast.Expr) []string {
+	var missing []string
+	noRead := isUnset(f, fields, "ReadTimeout");
+	if noRead && isUnset(f, fields, "ReadHeaderTimeout") {
+		missing = append(missing, "ReadHeaderTimeout");
+	}
+	if isUnset(f, fields, "WriteTimeout") {
+		missing = append(missing, "WriteTimeout");
+	}
+	if noRead && isUnset(f, fields, "IdleTimeout") {
+		missing = append(missing, "IdleTimeout");
+	}
+	if isUnset(f, fields, "MaxHeaderBytes") {
+		missing = append(missing, "MaxHeaderBytes");
+	}
+	return missing;
+}
+
+// httpServerCheck looks for HTTP servers that let slow clients hold connections open
+// (Slowloris), http.ListenAndServe and friends, which have no timeouts at all,
+// and http.Server literals that leave out timeouts or MaxHeaderBytes
+func httpServerCheck(f *File, node ast.Node) {
+	switch x := node.(type) {
+	case *ast.CallExpr:
+		if name := getCalleeName(f, x); defaultServerCalls[name] {
+			f.Reportf(x.Pos(), "%s uses a server with no timeouts, slow clients can hold connections open (Slowloris), use an http.Server with ReadHeaderTimeout, WriteTimeout and IdleTimeout: %s", strings.TrimPrefix(name, "net/"), f.ASTString(x));
+		}
+	case *ast.CompositeLit:
+		if !isNamedType(f.pkg.info.TypeOf(x), "net/http", "Server") {
+			return;
+		}
+		if missing := getMissingServerFields(f, getStructFields(f, x)); len(missing) > 0 {
+			f.Reportf(x.Pos(), "http.Server without %s, slow clients can hold connections open (Slowloris)", strings.Join(missing, ", "));
+		}
+	}
+}
@@ -1,28 +1,274 @@
 // Copyright 2018 Terence Tarvis.  All rights reserved.
-//  
+//
 
 package main
 
 import (
 	"go/ast"
+	"go/types"
 	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 func init() {
 	register("insecureCrypto",
 		"this test checks for insecure cryptography primitives",
 		cryptoCheck,
-		fileNode)
+		funcDecl, genDecl)
+}
+
+// insecurePkgs are packages of broken or weak primitives.
+// any package level function of these is reported where it is used.
+var insecurePkgs = map[string]bool{
+	"crypto/des":				true,
+	"crypto/md5":				true,
+	"crypto/rc4":				true,
+	"crypto/sha1":				true,
+	"golang.org/x/crypto/md4":		true,
+	"golang.org/x/crypto/ripemd160":	true,
+	"golang.org/x/crypto/blowfish":		true,
+	"golang.org/x/crypto/cast5":		true,
+}
+
+// insecureHashes are crypto.Hash values for insecure hashes
+// these are only reported when used for something that matters
+var insecureHashes = map[string]bool{
+	"crypto.MD4":		true,
+	"crypto.MD5":		true,
+	"crypto.SHA1":		true,
+	"crypto.MD5SHA1":	true,
+	"crypto.RIPEMD160":	true,
+}
+
+// ecbCalls encrypt a single block with no mode of operation
+var ecbCalls = map[string]bool{
+	"(crypto/cipher.Block).Encrypt":	true,
+	"(crypto/cipher.Block).Decrypt":	true,
 }
 
-func insecureCalls() map[string]bool {
-	calls := make(map[string]bool)
-	calls["crypto/des"] 	= true
-	calls["crypto/md5"] 	= true
-	calls["crypto/rc4"] 	= true
-	calls["crypto/sha1"] 	= true
+// cryptoSinks are calls that give a hash or cipher a security purpose
+var cryptoSinks = map[string]string{
+	"crypto/hmac.New":			"MACs",
+	"crypto/rsa.SignPKCS1v15":		"signatures",
+	"crypto/rsa.SignPSS":			"signatures",
+	"crypto/rsa.VerifyPKCS1v15":		"signatures",
+	"crypto/rsa.VerifyPSS":			"signatures",
+	"crypto/ecdsa.Sign":			"signatures",
+	"crypto/ecdsa.SignASN1":		"signatures",
+	"crypto/ecdsa.Verify":			"signatures",
+	"crypto/ecdsa.VerifyASN1":		"signatures",
+	"crypto/dsa.Sign":			"signatures",
+	"golang.org/x/crypto/pbkdf2.Key":	"password storage",
+	"crypto/pbkdf2.Key":			"password storage",
+	"golang.org/x/crypto/hkdf.New":		"key derivation",
+	"crypto/hkdf.Key":			"key derivation",
+}
+
+// usageNames are words of identifiers that give away what a value is used for
+var usageNames = []struct {
+	usage	string
+	names	[]string
+}{
+	{"password storage", []string{"password", "passwd", "passphrase"}},
+	{"signatures", []string{"signature", "signing", "signed"}},
+	{"MACs", []string{"hmac"}},
+	{"token generation", []string{"token", "session", "nonce", "apikey", "secret", "otp", "csrf"}},
+}
+
+// getNameUsage guesses what a value is used for from the words of its name
+// it returns an empty string if it can't tell
+func getNameUsage(name string) string {
+	for _, u := range usageNames {
+		if hasNameWord(name, u.names...) {
+			return u.usage;
+		}
+	}
+	words := splitName(name);
+	switch {
+	case len(words) == 0:
+		return "";
+	// pwd on its own is usually the working directory
+	case hasNameWord(name, "pwd") && len(words) > 1:
+		return "password storage";
+	case name == "sign", name == "sig":
+		return "signatures";
+	// isMac and onMac are about the platform
+	case hasNameWord(name, "mac") && words[0] != "is" && words[0] != "on":
+		return "MACs";
+	}
+	return "";
+}
 
-	return calls;
+// getInsecureObject returns the insecure function or crypto.Hash a selector refers to
+func getInsecureObject(f *File, sel *ast.SelectorExpr) (string, bool) {
+	switch obj := f.pkg.info.Uses[sel.Sel].(type) {
+	case *types.Func:
+		if obj.Pkg() == nil || !insecurePkgs[obj.Pkg().Path()] {
+			return "", false;
+		}
+		// methods are reached through a constructor which is already reported
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "", false;
+		}
+		return obj.FullName(), true;
+	case *types.Const:
+		if obj.Pkg() == nil {
+			return "", false;
+		}
+		name := obj.Pkg().Path() + "." + obj.Name();
+		return name, insecureHashes[name];
+	}
+	// the package couldn't be imported, so go by the import path alone
+	if id, ok := sel.X.(*ast.Ident); ok {
+		if pkgName, ok := f.pkg.info.Uses[id].(*types.PkgName); ok && insecurePkgs[pkgName.Imported().Path()] {
+			return pkgName.Imported().Path() + "." + sel.Sel.Name, true;
+		}
+	}
+	return "", false;
+}
+
+// getUsage works out what an insecure primitive is being used for.
+// path is from the use up to the file, as given by astutil.PathEnclosingInterval,
+// and body is the function, or file for package variables, the result is followed through
+func getUsage(f *File, body ast.Node, path []ast.Node) string {
+	// given straight to something like hmac.New or rsa.SignPKCS1v15
+	var seeds []ast.Expr
+	for _, n := range path[1:] {
+		// var h = md5.New(), in a function or at package level
+		if spec, ok := n.(*ast.ValueSpec); ok {
+			for _, name := range spec.Names {
+				seeds = append(seeds, name);
+			}
+			break;
+		}
+		if _, ok := n.(ast.Stmt); ok {
+			if assign, ok := n.(*ast.AssignStmt); ok {
+				seeds = assign.Lhs;
+			}
+			break;
+		}
+		if call, ok := n.(*ast.CallExpr); ok {
+			if usage, ok := cryptoSinks[getCalleeName(f, call)]; ok {
+				return usage;
+			}
+		}
+	}
+	// hashing something with a telling name i.e. md5.Sum([]byte(password))
+	if call, ok := path[0].(*ast.CallExpr); ok {
+		if usage := getArgsUsage(call.Args); usage != "" {
+			return usage;
+		}
+	}
+	// follow the result through to the variables and calls it reaches
+	tainted := make(map[types.Object]bool);
+	for _, x := range seeds {
+		if obj := getObject(f, x); obj != nil {
+			tainted[obj] = true;
+		}
+	}
+	if len(tainted) == 0 {
+		return "";
+	}
+	spreadTaint(f, body, tainted, nil);
+	for obj := range tainted {
+		if usage := getNameUsage(obj.Name()); usage != "" {
+			return usage;
+		}
+	}
+	usage := "";
+	ast.Inspect(body, func(n ast.Node) bool {
+		if usage != "" {
+			return false;
+		}
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		if sink, ok := cryptoSinks[getCalleeName(f, call)]; ok {
+			for _, arg := range call.Args {
+				if usesTainted(f, arg, tainted, nil) {
+					usage = sink;
+				}
+			}
+			if usage != "" {
+				return false;
+			}
+		}
+		// h.Write([]byte(password))
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && usesTainted(f, sel.X, tainted, nil) {
+			usage = getArgsUsage(call.Args);
+		}
+		return true;
+	});
+	return usage;
+}
+
+// getArgsUsage guesses a usage from the identifiers in call arguments
+func getArgsUsage(args []ast.Expr) string {
+	usage := "";
+	for _, arg := range args {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && usage == "" {
+				usage = getNameUsage(id.Name);
+			}
+			return usage == "";
+		});
+	}
+	return usage;
+}
+
+// checkCryptoUses reports insecure primitives used in node.
+// body is what their results are followed through to find a usage
+func checkCryptoUses(f *File, node, body ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			if ecbCalls[getCalleeName(f, x)] {
+				f.Reportf(x.Pos(), "block cipher used directly, this is ECB mode: %s", f.ASTString(x));
+			}
+		case *ast.SelectorExpr:
+			name, ok := getInsecureObject(f, x);
+			if !ok {
+				return true;
+			}
+			path, _ := astutil.PathEnclosingInterval(f.file, x.Pos(), x.End());
+			// report calls at the call, not the function name
+			site := ast.Node(x);
+			if call, ok := path[1].(*ast.CallExpr); ok && call.Fun == x {
+				site = call;
+				path = path[1:];
+				// passwordStorage reports fast hashes of passwords in functions
+				if body != ast.Node(f.file) && isPasswordHash(f, body, call) {
+					return true;
+				}
+			}
+			usage := getUsage(f, body, path);
+			if usage != "" {
+				f.Reportf(site.Pos(), "high severity: insecure cryptographic primitive %s used for %s: %s", name, usage, f.ASTString(site.(ast.Expr)));
+			} else if !insecureHashes[name] {
+				f.Reportf(site.Pos(), "audit use of insecure cryptographic primitive %s: %s", name, f.ASTString(site.(ast.Expr)));
+			}
+		}
+		return true;
+	});
+}
+
+func cryptoCheck(f *File, node ast.Node) {
+	switch decl := node.(type) {
+	case *ast.FuncDecl:
+		if decl.Body != nil {
+			checkCryptoUses(f, decl.Body, decl.Body);
+		}
+	case *ast.GenDecl:
+		// declarations inside functions are covered by the function
+		for _, d := range f.file.Decls {
+			if d == decl {
+				checkCryptoUses(f, decl, f.file);
+			}
+		}
+	}
+	return;
 }
 
 // getImports returns all the imports for a full file AST node
@@ -36,18 +282,3 @@ func getImports(fn *ast.File) []string {
 	}
 	return imported;
 }
-
-func cryptoCheck(f *File, node ast.Node) {
-	var imported []string;
-	insecure := insecureCalls();
-
-	if fileNode, ok := node.(*ast.File); ok {
-		imported = getImports(fileNode);
-	}
-	for _, call := range imported {
-		if _, ok := insecure[call]; ok {
-			f.Reportf(node.Pos(), "insecure cryptographic import: %s", call);
-		}
-	}
-	return;
-}
@@ -1,30 +1,362 @@
 // Copyright 2018 Terence Tarvis.  All rights reserved.
-//  
+//
 
 package main
 
 import (
 	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 func init() {
 	register("insecureRand",
 		"this is test to check if random nums generated insecurely",
 		randCheck,
-		fileNode)
+		funcDecl)
 }
 
-func randCheck(f *File, node ast.Node) {
-	var imported []string
-	
-	if fileNode, ok := node.(*ast.File); ok {
-		imported = getImports(fileNode);
+// randPkgs are packages of predictable random number generators
+var randPkgs = map[string]bool{
+	"math/rand":	true,
+	"math/rand/v2":	true,
+}
+
+// timeSeeds are predictable values that get used to seed or stand in for random numbers.
+// they only count where they seed a generator or go straight into a sink
+var timeSeeds = map[string]bool{
+	"(time.Time).UnixNano":	true,
+}
+
+// randSinkNames are words of identifiers for things that need to be unpredictable
+var randSinkNames = []string{
+	"token", "session", "nonce", "salt", "key", "secret", "password", "passwd",
+	"otp", "reset", "csrf", "apikey", "verification", "uuid", "guid",
+}
+
+// returnsRandom caches whether a function in the package returns
+// values from a predictable random number generator
+var returnsRandom = make(map[*types.Func]bool)
+
+// isRandSinkName checks if a name is for something that needs to be unpredictable.
+// fields qualified by qualifyField, i.e. Session.ID, are judged by the field name,
+// and only an ID takes on what its struct is for
+func isRandSinkName(name string) bool {
+	owner := "";
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		owner, name = name[:i], name[i + 1:];
+	}
+	if hasNameWord(name, randSinkNames...) {
+		return true;
+	}
+	if !hasNameWord(name, "id") {
+		return false;
+	}
+	// IDs only need to be unpredictable when they stand in for a credential, not requestID
+	return hasNameWord(name, "auth", "login") || hasNameWord(owner, randSinkNames...) || hasNameWord(owner, "auth", "login");
+}
+
+// getLhsName returns the name of what is being assigned to
+// i.e. token, user.ResetCode or codes[user]
+func getLhsName(x ast.Expr) string {
+	switch e := x.(type) {
+	case *ast.Ident:
+		return e.Name;
+	case *ast.SelectorExpr:
+		return e.Sel.Name;
+	case *ast.IndexExpr:
+		return getLhsName(e.X);
+	case *ast.StarExpr:
+		return getLhsName(e.X);
+	case *ast.ParenExpr:
+		return getLhsName(e.X);
+	}
+	return "";
+}
+
+// qualifyField prefixes a field name with the name of its struct type,
+// i.e. Session.ID, so a field is judged by what it belongs to
+func qualifyField(t types.Type, name string) string {
+	if t == nil {
+		return name;
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	if named, ok := t.(*types.Named); ok {
+		if _, ok := named.Underlying().(*types.Struct); ok {
+			return named.Obj().Name() + "." + name;
+		}
+	}
+	return name;
+}
+
+// getSinkName returns the name of what is being assigned to
+// with struct fields qualified by their type, i.e. token or Session.ID
+func getSinkName(f *File, x ast.Expr) string {
+	if sel, ok := x.(*ast.SelectorExpr); ok {
+		return qualifyField(f.pkg.info.TypeOf(sel.X), sel.Sel.Name);
+	}
+	return getLhsName(x);
+}
+
+// getKeySinkName returns the name of the field or key a composite literal element is for
+func getKeySinkName(f *File, lit *ast.CompositeLit, kv *ast.KeyValueExpr) string {
+	if id, ok := kv.Key.(*ast.Ident); ok {
+		return qualifyField(f.pkg.info.TypeOf(lit), id.Name);
+	}
+	return getLhsName(kv.Key);
+}
+
+// isCryptoAPI checks if a call is to a cryptographic package
+func isCryptoAPI(f *File, call *ast.CallExpr) bool {
+	name := strings.TrimLeft(getCalleeName(f, call), "(*");
+	return strings.HasPrefix(name, "crypto/") || strings.HasPrefix(name, "golang.org/x/crypto/");
+}
+
+// getRandSource checks if a call produces a predictable random value
+// and returns a description of where it comes from
+func getRandSource(f *File, call *ast.CallExpr) (string, bool) {
+	name := getCalleeName(f, call);
+	if timeSeeds[name] {
+		return name, true;
+	}
+	fn := getCalledFunc(f, call);
+	if fn == nil {
+		// the package may have failed to import
+		for pkg := range randPkgs {
+			if strings.HasPrefix(name, pkg + ".") {
+				return name, true;
+			}
+		}
+		return "", false;
+	}
+	if fn.Pkg() != nil && randPkgs[fn.Pkg().Path()] {
+		return fn.FullName(), true;
+	}
+	if isRandFunc(f, fn) {
+		return fn.Name() + ", which uses math/rand,", true;
+	}
+	return "", false;
+}
+
+// isRandFunc checks if a function in the package returns predictable random values
+func isRandFunc(f *File, fn *types.Func) bool {
+	if result, ok := returnsRandom[fn]; ok {
+		return result;
+	}
+	// guard against recursion while this one is worked out
+	returnsRandom[fn] = false;
+	decl := getFuncDecl(f, fn);
+	if decl == nil || decl.Body == nil {
+		return false;
+	}
+	tainted := make(map[types.Object]bool);
+	sources := make(map[*ast.CallExpr]bool);
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			// times only count where they go straight into a sink, not when returned
+			if source, ok := getRandSource(f, call); ok && !timeSeeds[source] {
+				sources[call] = true;
+				for _, x := range getResultVars(f, call) {
+					tainted[x] = true;
+				}
+			}
+		}
+		return true;
+	});
+	if len(sources) == 0 {
+		return false;
 	}
+	spreadTaint(f, decl.Body, tainted, nil);
+	result := false;
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		ret, ok := n.(*ast.ReturnStmt);
+		if !ok {
+			return !result;
+		}
+		for _, x := range ret.Results {
+			if usesTainted(f, x, tainted, nil) || containsCall(x, sources) {
+				result = true;
+			}
+		}
+		return !result;
+	});
+	returnsRandom[fn] = result;
+	return result;
+}
+
+// containsCall checks if an expression contains any of the given calls
+func containsCall(x ast.Expr, calls map[*ast.CallExpr]bool) bool {
+	found := false;
+	ast.Inspect(x, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && calls[call] {
+			found = true;
+		}
+		return !found;
+	});
+	return found;
+}
 
-	for _, pkg := range imported {
-		if(pkg == "math/rand") {
-			f.Reportf(node.Pos(), "audit the use of insecure random number generator: import: %s", pkg);
-		} 
+// getDirectSink returns the security sensitive thing a value is used for
+// within its own statement, or an empty string. path is from the value up to the file.
+// if straight is set the value can't pass through anything but conversions and arithmetic
+func getDirectSink(f *File, fun *ast.FuncDecl, path []ast.Node, straight bool) string {
+	for i, n := range path[1:] {
+		switch node := n.(type) {
+		case *ast.CallExpr:
+			if isCryptoAPI(f, node) {
+				return getCalleeName(f, node);
+			}
+			if straight && !f.pkg.info.Types[node.Fun].IsType() {
+				return "";
+			}
+		case *ast.KeyValueExpr:
+			if lit, ok := path[i + 2].(*ast.CompositeLit); ok {
+				if name := getKeySinkName(f, lit, node); isRandSinkName(name) {
+					return name;
+				}
+			}
+		case *ast.ReturnStmt:
+			if isRandSinkName(fun.Name.Name) {
+				return fun.Name.Name;
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range node.Lhs {
+				if name := getSinkName(f, lhs); isRandSinkName(name) {
+					return name;
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range node.Names {
+				if isRandSinkName(name.Name) {
+					return name.Name;
+				}
+			}
+		case *ast.ParenExpr, *ast.BinaryExpr, *ast.UnaryExpr:
+		default:
+			if straight {
+				return "";
+			}
+		}
+		if _, ok := n.(ast.Stmt); ok {
+			break;
+		}
+	}
+	return "";
+}
+
+// getTimeSink returns what a time standing in for a random number is used for.
+// it only counts where it seeds a generator, which is then followed instead,
+// or goes straight into a sink, so cacheKey := fmt.Sprint(time.Now().UnixNano()) is fine
+func getTimeSink(f *File, fun *ast.FuncDecl, call *ast.CallExpr) string {
+	path, _ := astutil.PathEnclosingInterval(f.file, call.Pos(), call.End());
+	for _, n := range path[1:] {
+		switch node := n.(type) {
+		case *ast.ParenExpr, *ast.BinaryExpr, *ast.UnaryExpr:
+			continue;
+		case *ast.CallExpr:
+			if f.pkg.info.Types[node.Fun].IsType() {
+				continue;
+			}
+			// rand.NewSource(time.Now().UnixNano())
+			if _, ok := getRandSource(f, node); ok {
+				return getRandSink(f, fun, node);
+			}
+		}
+		break;
+	}
+	return getDirectSink(f, fun, path, true);
+}
+
+// getRandSink follows a random value through a function and returns
+// the first security sensitive thing it is used for, or an empty string
+func getRandSink(f *File, fun *ast.FuncDecl, call *ast.CallExpr) string {
+	path, _ := astutil.PathEnclosingInterval(f.file, call.Pos(), call.End());
+	if sink := getDirectSink(f, fun, path, false); sink != "" {
+		return sink;
+	}
+	// used through variables
+	tainted := make(map[types.Object]bool);
+	for _, obj := range getResultVars(f, call) {
+		tainted[obj] = true;
+	}
+	if len(tainted) == 0 {
+		return "";
+	}
+	spreadTaint(f, fun.Body, tainted, nil);
+	for obj := range tainted {
+		if isRandSinkName(obj.Name()) {
+			return obj.Name();
+		}
+	}
+	sink := "";
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.CallExpr:
+			if isCryptoAPI(f, node) {
+				for _, arg := range node.Args {
+					if usesTainted(f, arg, tainted, nil) {
+						sink = getCalleeName(f, node);
+					}
+				}
+			}
+		case *ast.CompositeLit:
+			for _, elt := range node.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr);
+				if !ok {
+					continue;
+				}
+				if name := getKeySinkName(f, node, kv); isRandSinkName(name) && usesTainted(f, kv.Value, tainted, nil) {
+					sink = name;
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range node.Lhs {
+				if name := getSinkName(f, lhs); isRandSinkName(name) && i < len(node.Rhs) && usesTainted(f, node.Rhs[i], tainted, nil) {
+					sink = name;
+				}
+			}
+		case *ast.ReturnStmt:
+			for _, x := range node.Results {
+				if isRandSinkName(fun.Name.Name) && usesTainted(f, x, tainted, nil) {
+					sink = fun.Name.Name;
+				}
+			}
+		}
+		return sink == "";
+	});
+	return sink;
+}
+
+func randCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
 	}
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		source, ok := getRandSource(f, call);
+		if !ok {
+			return true;
+		}
+		var sink string
+		if timeSeeds[source] {
+			sink = getTimeSink(f, fun, call);
+		} else {
+			sink = getRandSink(f, fun, call);
+		}
+		if sink != "" {
+			f.Reportf(call.Pos(), "insecure random number from %s used for %s: %s", source, sink, f.ASTString(call));
+			// the arguments are part of the same value
+			return false;
+		}
+		return true;
+	});
 	return;
 }
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"go/ast"
 	"go/build"
+	"go/constant"
 	"go/token"
 	"go/parser"
 	"go/printer"
@@ -15,11 +16,13 @@ import (
 	"os"
 	"path/filepath"
 
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/loader"
 	"golang.org/x/tools/go/pointer"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/ssa/ssautil"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
 var stdImporter types.Importer
@@ -28,6 +31,8 @@ var (
 	source 	= 	flag.Bool("source", false, "import from source instead of compiled object files")
 	verbose = 	flag.Bool("verbose", false, "verbose logging and warnings")
 	test	=	flag.Bool("test", false, "run checker on test files")
+	configFile =	flag.String("config", "", "path to a JSON configuration file")
+	secrets	=	flag.Bool("secrets", false, "also scan .env, YAML, JSON, .properties, Dockerfiles and embedded files for credentials")
 )
 
 // a global variable for the exit code.
@@ -43,12 +48,14 @@ var (
 	binaryExpr	*ast.BinaryExpr
 	callExpr	*ast.CallExpr
 	compositeLit	*ast.CompositeLit
+	deferStmt	*ast.DeferStmt
 	exprStmt	*ast.ExprStmt
 	fileNode	*ast.File
 	forStmt		*ast.ForStmt
 	funcDecl	*ast.FuncDecl
 	funcLit		*ast.FuncLit
 	genDecl		*ast.GenDecl
+	goStmt		*ast.GoStmt
 	interfaceType	*ast.InterfaceType
 	rangeStmt	*ast.RangeStmt
 	returnStmt	*ast.ReturnStmt
@@ -133,6 +140,8 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 		key = callExpr
 	case *ast.CompositeLit:
 		key = compositeLit
+	case *ast.DeferStmt:
+		key = deferStmt
 	case *ast.ExprStmt:
 		key = exprStmt
 	case *ast.File:
@@ -145,6 +154,8 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 		key = funcLit
 	case *ast.GenDecl:
 		key = genDecl
+	case *ast.GoStmt:
+		key = goStmt
 	case *ast.InterfaceType:
 		key = interfaceType
 	case *ast.RangeStmt:
@@ -163,6 +174,7 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 
 type Package struct {
 	path	string
+	files	[]*ast.File
 	types 	map[ast.Expr]types.TypeAndValue;
 	typePkg	*types.Package
 	info	*types.Info
@@ -194,7 +206,10 @@ func (pkg *Package) check(fs *token.FileSet, astFiles []*ast.File) error {
 	}
 
 	info := types.Info{
-		Types: pkg.types,
+		Types:		pkg.types,
+		Defs:		make(map[*ast.Ident]types.Object),
+		Uses:		make(map[*ast.Ident]types.Object),
+		Selections:	make(map[*ast.SelectorExpr]*types.Selection),
 	}
 
 	// Type-Check the package.
@@ -273,6 +288,9 @@ func checkPackage(names []string) {
 			}
 			astFiles = append(astFiles, parsedFile);
 		}
+		if *secrets && parsedFile != nil {
+			scanEmbeddedFiles(name, parsedFile);
+		}
 		file := &File{
 			fset:	fset,
 			name:	name,
@@ -284,6 +302,7 @@ func checkPackage(names []string) {
 		return;
 	}
 	pkg := new(Package);
+	pkg.files = astFiles;
 	
 	// Type check package and
 	// generate information about it
@@ -396,6 +415,12 @@ func visit(path string, info os.FileInfo, err error) error {
 	if !info.IsDir() {
 		return nil
 	}
+	if *secrets && info.Name() == ".git" {
+		return filepath.SkipDir;
+	}
+	if *secrets {
+		scanSecretDir(path);
+	}
 	checkPackageDir(path);
 	return nil;
 }
@@ -425,6 +450,167 @@ func getFuncName(node ast.Node) string {
 	return ""
 }
 
+// getCalledFunc returns the function or method called
+// or nil if it is not a declared function or can't be resolved
+func getCalledFunc(f *File, call *ast.CallExpr) *types.Func {
+	if fn, ok := typeutil.Callee(f.pkg.info, call).(*types.Func); ok {
+		return fn;
+	}
+	return nil;
+}
+
+// getCalleeName returns the fully qualified name of a called function or method
+// i.e. text/template.New or (*text/template.Template).Parse
+// If a package could not be imported, package level functions are still
+// named from the import path, methods are not.
+// it returns an empty string if the callee can't be resolved through type info
+func getCalleeName(f *File, call *ast.CallExpr) string {
+	if fn := getCalledFunc(f, call); fn != nil {
+		return fn.FullName();
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if pkgName, ok := f.pkg.info.Uses[id].(*types.PkgName); ok {
+				return pkgName.Imported().Path() + "." + sel.Sel.Name;
+			}
+		}
+	}
+	return "";
+}
+
+// getFuncDecl finds the declaration of a function in the package being checked
+func getFuncDecl(f *File, fn *types.Func) *ast.FuncDecl {
+	for _, file := range f.pkg.files {
+		for _, decl := range file.Decls {
+			if fun, ok := decl.(*ast.FuncDecl); ok {
+				if f.pkg.info.Defs[fun.Name] == fn {
+					return fun;
+				}
+			}
+		}
+	}
+	return nil;
+}
+
+// getConstInt evaluates a constant integer expression using type info
+// so named constants like tls.VersionTLS10 and hex literals are resolved
+func getConstInt(f *File, x ast.Expr) (int64, bool) {
+	tv, ok := f.pkg.info.Types[x];
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return 0, false;
+	}
+	return constant.Int64Val(tv.Value);
+}
+
+// getConstBool evaluates a constant boolean expression using type info
+func getConstBool(f *File, x ast.Expr) (bool, bool) {
+	tv, ok := f.pkg.info.Types[x];
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.Bool {
+		return false, false;
+	}
+	return constant.BoolVal(tv.Value), true;
+}
+
+// getConstString evaluates a constant string expression using type info
+func getConstString(f *File, x ast.Expr) (string, bool) {
+	tv, ok := f.pkg.info.Types[x];
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false;
+	}
+	return constant.StringVal(tv.Value), true;
+}
+
+// isNil checks if an expression is the predeclared nil
+func isNil(f *File, x ast.Expr) bool {
+	tv, ok := f.pkg.info.Types[x];
+	return ok && tv.IsNil();
+}
+
+// isNamedType checks if a type, or what it points to, is the named type path.name
+// i.e. net/http.Server
+func isNamedType(t types.Type, path, name string) bool {
+	if t == nil {
+		return false;
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	named, ok := types.Unalias(t).(*types.Named);
+	if !ok {
+		return false;
+	}
+	obj := named.Obj();
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name;
+}
+
+// getStructFields returns the fields set on a struct literal by name,
+// along with any set later on the variable it is assigned to, i.e.
+//
+//	srv := &http.Server{Addr: addr}
+//	srv.ReadTimeout = time.Second
+func getStructFields(f *File, lit *ast.CompositeLit) map[string]ast.Expr {
+	fields := make(map[string]ast.Expr);
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				fields[key.Name] = kv.Value;
+			}
+		}
+	}
+	path, _ := astutil.PathEnclosingInterval(f.file, lit.Pos(), lit.End());
+	var obj types.Object
+	var body ast.Node
+	for i, n := range path {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if obj == nil && len(x.Lhs) == len(x.Rhs) {
+				for j, rhs := range x.Rhs {
+					if rhs == path[i - 1] {
+						obj = getObject(f, x.Lhs[j]);
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			if obj == nil && len(x.Names) == len(x.Values) {
+				for j, value := range x.Values {
+					if value == path[i - 1] {
+						obj = f.pkg.info.Defs[x.Names[j]];
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			body = x.Body;
+		case *ast.FuncLit:
+			body = x.Body;
+		}
+		if body != nil {
+			break;
+		}
+	}
+	if obj == nil || body == nil {
+		return fields;
+	}
+	addAssignedFields(f, body, obj, fields);
+	return fields;
+}
+
+// addAssignedFields adds the fields of a struct variable assigned in a function body
+// i.e. ReadTimeout for srv.ReadTimeout = time.Second
+func addAssignedFields(f *File, body ast.Node, obj types.Object, fields map[string]ast.Expr) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.AssignStmt);
+		if !ok || len(stmt.Lhs) != len(stmt.Rhs) {
+			return true;
+		}
+		for i, lhs := range stmt.Lhs {
+			if sel, ok := lhs.(*ast.SelectorExpr); ok && getObject(f, sel.X) == obj {
+				fields[sel.Sel.Name] = stmt.Rhs[i];
+			}
+		}
+		return true;
+	});
+}
+
 // getFullFuncName extracts a full function name path i.e ioutil.ReadAll
 func getFullFuncName(node ast.Node) (string, error) {
 	var names []string
@@ -453,6 +639,16 @@ func main() {
 	var runOnDirs, runOnFiles bool;
 	flag.Parse();
 
+	if err := loadConfig(*configFile); err != nil {
+		warnf("error loading configuration: %s", err);
+		os.Exit(exitCode);
+	}
+
+	if flag.Arg(0) == "history" {
+		runHistory(flag.Args()[1:]);
+		os.Exit(exitCode);
+	}
+
 	for _, name := range flag.Args() {
 		// check to see if cl argument is a directory
 		f, err := os.Stat(name);
@@ -483,6 +679,13 @@ func main() {
 	}
 	// else they are just file names
 	fileNames := flag.Args();	
+	if *secrets {
+		for _, name := range fileNames {
+			if !strings.HasSuffix(name, ".go") {
+				scanSecretFile(name, getSecretFileType(name));
+			}
+		}
+	}
 	checkPackage(fileNames);
 	return;
 }
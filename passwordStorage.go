@@ -0,0 +1,262 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("passwordStorage",
+		"this checks for passwords hashed with fast or unsalted hashes or stored in plaintext",
+		passwordStorageCheck,
+		funcDecl)
+}
+
+// fastHashes are general purpose hashes, too fast to slow down password guessing
+// the value is true for functions that hash their argument directly
+var fastHashes = map[string]bool{
+	"crypto/md5.Sum":			true,
+	"crypto/sha1.Sum":			true,
+	"crypto/sha256.Sum224":			true,
+	"crypto/sha256.Sum256":			true,
+	"crypto/sha512.Sum384":			true,
+	"crypto/sha512.Sum512":			true,
+	"crypto/sha3.Sum256":			true,
+	"crypto/sha3.Sum512":			true,
+	"golang.org/x/crypto/sha3.Sum256":	true,
+	"golang.org/x/crypto/sha3.Sum512":	true,
+	"golang.org/x/crypto/blake2b.Sum256":	true,
+	"golang.org/x/crypto/blake2b.Sum512":	true,
+	"crypto/md5.New":			false,
+	"crypto/sha1.New":			false,
+	"crypto/sha256.New":			false,
+	"crypto/sha256.New224":			false,
+	"crypto/sha512.New":			false,
+	"crypto/sha512.New384":			false,
+	"crypto/sha3.New256":			false,
+	"crypto/sha3.New512":			false,
+	"golang.org/x/crypto/sha3.New256":	false,
+	"golang.org/x/crypto/sha3.New512":	false,
+}
+
+// sqlWrites are database calls that can store their arguments
+var sqlWrites = map[string]bool{
+	"(*database/sql.DB).Exec":		true,
+	"(*database/sql.DB).ExecContext":	true,
+	"(*database/sql.Tx).Exec":		true,
+	"(*database/sql.Tx).ExecContext":	true,
+	"(*database/sql.Stmt).Exec":		true,
+	"(*database/sql.Stmt).ExecContext":	true,
+	"(*database/sql.Conn).ExecContext":	true,
+}
+
+// fileWrites are calls that write their arguments to a file
+var fileWrites = map[string]bool{
+	"os.WriteFile":				true,
+	"io/ioutil.WriteFile":			true,
+	"(*os.File).Write":			true,
+	"(*os.File).WriteString":		true,
+	"(*bufio.Writer).Write":		true,
+	"(*bufio.Writer).WriteString":		true,
+	"(*encoding/csv.Writer).Write":		true,
+}
+
+// fileFormatters write to their first argument, which only matters if it is a file
+var fileFormatters = map[string]bool{
+	"fmt.Fprint":		true,
+	"fmt.Fprintf":		true,
+	"fmt.Fprintln":		true,
+	"io.WriteString":	true,
+}
+
+// isPasswordName checks if an identifier names a password, and not a hash of one.
+// names are judged on whole words, and pwd alone is usually the working directory
+func isPasswordName(name string) bool {
+	if getNameUsage(name) != "password storage" {
+		return false;
+	}
+	return !hasNameWord(name, "hash", "hashed", "digest", "crypt", "encrypted", "bcrypt", "salt", "policy", "reset", "len", "length");
+}
+
+// getPasswordExpr returns a password held directly by an argument
+// i.e. password, user.Password or []byte(pw) but not bcrypt.GenerateFromPassword(pw)
+func getPasswordExpr(f *File, x ast.Expr) ast.Expr {
+	switch e := x.(type) {
+	case *ast.Ident:
+		if isPasswordName(e.Name) {
+			return e;
+		}
+	case *ast.SelectorExpr:
+		if isPasswordName(e.Sel.Name) {
+			return e;
+		}
+	case *ast.ParenExpr:
+		return getPasswordExpr(f, e.X);
+	case *ast.StarExpr:
+		return getPasswordExpr(f, e.X);
+	case *ast.CallExpr:
+		// only look through conversions like []byte(password)
+		if len(e.Args) == 1 && f.pkg.info.Types[e.Fun].IsType() {
+			return getPasswordExpr(f, e.Args[0]);
+		}
+	}
+	return nil;
+}
+
+// hashInputs sorts the identifiers going into a hash into passwords and anything else
+func hashInputs(f *File, x ast.Expr) (password bool, other bool) {
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.SelectorExpr:
+			if isPasswordName(e.Sel.Name) {
+				password = true;
+			} else if _, ok := f.pkg.info.Uses[e.Sel].(*types.Var); ok {
+				other = true;
+			}
+			// don't count the struct holding the field
+			return false;
+		case *ast.Ident:
+			if isPasswordName(e.Name) {
+				password = true;
+			} else if _, ok := f.pkg.info.Uses[e].(*types.Var); ok {
+				other = true;
+			}
+		}
+		return true;
+	});
+	return password, other;
+}
+
+// getHashedInputs sorts what a fast hash call hashes into passwords and anything else.
+// body is the function the call is in, where writes to a hasher are looked for
+func getHashedInputs(f *File, body ast.Node, call *ast.CallExpr, name string) (password bool, other bool) {
+	if fastHashes[name] {
+		// md5.Sum(password)
+		for _, arg := range call.Args {
+			p, o := hashInputs(f, arg);
+			password, other = password || p, other || o;
+		}
+		return password, other;
+	}
+	// h := sha256.New(); h.Write(password)
+	vars := getResultVars(f, call);
+	if len(vars) == 0 {
+		return false, false;
+	}
+	hasher := vars[0];
+	ast.Inspect(body, func(n ast.Node) bool {
+		write, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		var data []ast.Expr
+		switch getFuncName(write) {
+		case "Write":
+			if sel, ok := write.Fun.(*ast.SelectorExpr); ok && getObject(f, sel.X) == hasher {
+				data = write.Args;
+			}
+		case "WriteString":
+			// io.WriteString(h, password)
+			if len(write.Args) == 2 && getObject(f, write.Args[0]) == hasher {
+				data = write.Args[1:];
+			} else if sel, ok := write.Fun.(*ast.SelectorExpr); ok && getObject(f, sel.X) == hasher {
+				data = write.Args;
+			}
+		}
+		for _, x := range data {
+			p, o := hashInputs(f, x);
+			password, other = password || p, other || o;
+		}
+		return true;
+	});
+	return password, other;
+}
+
+// isPasswordHash checks if a call is a fast hash of a password,
+// which passwordStorage reports
+func isPasswordHash(f *File, body ast.Node, call *ast.CallExpr) bool {
+	name := getCalleeName(f, call);
+	if _, ok := fastHashes[name]; !ok {
+		return false;
+	}
+	password, _ := getHashedInputs(f, body, call, name);
+	return password;
+}
+
+// checkPasswordHash looks for passwords going into fast hashes
+func checkPasswordHash(f *File, fun *ast.FuncDecl, call *ast.CallExpr, name string) {
+	formatString := "password hashed with fast hash %s, use bcrypt, scrypt or argon2: %s";
+	unsaltedString := "password hashed with fast hash %s and no salt, use bcrypt, scrypt or argon2: %s";
+	password, other := getHashedInputs(f, fun.Body, call, name);
+	if password && !other {
+		f.Reportf(call.Pos(), unsaltedString, name, f.ASTString(call));
+	} else if password {
+		f.Reportf(call.Pos(), formatString, name, f.ASTString(call));
+	}
+}
+
+// isWriteToFile checks if the first argument of an fmt.Fprintf like call is a file
+func isWriteToFile(f *File, call *ast.CallExpr) bool {
+	if len(call.Args) == 0 {
+		return false;
+	}
+	t := f.pkg.info.TypeOf(call.Args[0]);
+	if t == nil {
+		return false;
+	}
+	s := t.String();
+	return s == "*os.File" || s == "*bufio.Writer";
+}
+
+// checkPlaintextPassword looks for passwords written to a database or file as they are
+func checkPlaintextPassword(f *File, call *ast.CallExpr, name string) {
+	args := call.Args;
+	switch {
+	case sqlWrites[name]:
+		// only inserts and updates store anything
+		for _, arg := range args {
+			if query, ok := getConstString(f, arg); ok {
+				query = strings.ToLower(query);
+				if !strings.Contains(query, "insert") && !strings.Contains(query, "update") {
+					return;
+				}
+			}
+		}
+	case fileWrites[name]:
+	case fileFormatters[name] && isWriteToFile(f, call):
+		args = args[1:];
+	default:
+		return;
+	}
+	for _, arg := range args {
+		if password := getPasswordExpr(f, arg); password != nil {
+			f.Reportf(call.Pos(), "password stored in plaintext, %s: %s", f.ASTString(password), f.ASTString(call));
+			return;
+		}
+	}
+}
+
+func passwordStorageCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
+	}
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		name := getCalleeName(f, call);
+		if _, ok := fastHashes[name]; ok {
+			checkPasswordHash(f, fun, call, name);
+			return true;
+		}
+		checkPlaintextPassword(f, call, name);
+		return true;
+	});
+	return;
+}
@@ -1,47 +1,233 @@
 // Copyright 2018 Terence Tarvis.  All rights reserved.
-//  
+//
 
 package main
 
 import (
 	"go/ast"
-	"strings"
+	"go/token"
+	"go/types"
 )
 
 func init() {
 	register("readAll",
-		"this tests checks of use of ioutil.ReadAll needs to be audited",
+		"this tests for untrusted streams read into memory without a limit",
 		readAllCheck,
-		callExpr)
+		funcDecl)
 }
 
-// this checks for the bad function
-// but maybe abstract this and create a function name extractor
-// then throw all the bad functions together or check for them
-// using it
-func readAllCheck(f *File, node ast.Node) {
-	// the names of the called functions
-	// new function getFullFuncName does all this work.
-	// todo: replace
-	var names []string
-	var callName string	
-	if call, ok := node.(*ast.CallExpr); ok {
-		if fun, ok := call.Fun.(*ast.SelectorExpr); ok {
-			// SelectorExpr has two fields
-			// X and Sel
-			// X (through reflection) was found to be an Ident
-			// Sel has field Name
-			// Ident's have a field Name also.
-			if id, ok := (fun.X).(*ast.Ident); ok {
-				names = append(names, id.Name);
-				names = append(names, fun.Sel.Name);	
-				callName = strings.Join(names, "/")
-				if(callName == "ioutil/ReadAll") {
-					callStr := f.ASTString(call);
-					f.Reportf(node.Pos(), "audit use of ioutil.ReadAll %s", callStr);
-				} 	
+// decompressors return readers whose output can be far bigger than their input
+var decompressors = map[string]bool{
+	"compress/gzip.NewReader":		true,
+	"compress/zlib.NewReader":		true,
+	"compress/zlib.NewReaderDict":		true,
+	"compress/flate.NewReader":		true,
+	"compress/flate.NewReaderDict":		true,
+	"compress/bzip2.NewReader":		true,
+	"compress/lzw.NewReader":		true,
+	"(*archive/zip.File).Open":		true,
+	"github.com/klauspost/compress/zstd.NewReader":	true,
+}
+
+// boundingCalls return readers that stop after a limit
+var boundingCalls = map[string]bool{
+	"io.LimitReader":		true,
+	"net/http.MaxBytesReader":	true,
+}
+
+// passThroughReaders wrap a reader, given as their first argument, without limiting it
+var passThroughReaders = map[string]bool{
+	"bufio.NewReader":		true,
+	"bufio.NewReaderSize":		true,
+	"io.TeeReader":			true,
+	"archive/tar.NewReader":	true,
+	"encoding/json.NewDecoder":	true,
+	"encoding/xml.NewDecoder":	true,
+}
+
+// connTypes are network connections, which can send any amount of data
+var connTypes = map[string]bool{
+	"net.Conn":		true,
+	"*net.TCPConn":		true,
+	"*net.UnixConn":	true,
+	"*crypto/tls.Conn":	true,
+}
+
+// readAllSinks read everything from the reader given as the argument at the index
+var readAllSinks = map[string]int{
+	"io.ReadAll":			0,
+	"io/ioutil.ReadAll":		0,
+	"io.Copy":			1,
+	"io.CopyBuffer":		1,
+	"(*bytes.Buffer).ReadFrom":	0,
+}
+
+// readAllMethods read everything, or until a delimiter that may never come,
+// from the reader or decoder they are called on
+var readAllMethods = map[string]bool{
+	"(*encoding/json.Decoder).Decode":	true,
+	"(*encoding/xml.Decoder).Decode":	true,
+	"(*bufio.Reader).ReadString":		true,
+	"(*bufio.Reader).ReadBytes":		true,
+}
+
+// memoryWriters are destinations for io.Copy that hold everything in memory
+var memoryWriters = map[string]bool{
+	"*bytes.Buffer":	true,
+	"*strings.Builder":	true,
+}
+
+// streamState follows the readers a function holds through its variables.
+// limited holds the requests whose Body has been replaced with a bounded reader
+type streamState struct {
+	streams	map[types.Object]string
+	limited	map[types.Object]bool
+}
+
+// getStream returns what kind of untrusted stream an expression reads from
+// i.e. a request body, or an empty string if it is bounded or not untrusted
+func getStream(f *File, x ast.Expr, state *streamState) string {
+	switch e := unparen(x).(type) {
+	case *ast.Ident:
+		if obj := getObject(f, e); obj != nil {
+			if kind, ok := state.streams[obj]; ok {
+				return kind;
+			}
+		}
+	case *ast.SelectorExpr:
+		// r.Body
+		if t := f.pkg.info.TypeOf(e.X); e.Sel.Name == "Body" && t != nil && isRequest(t) {
+			if obj := getObject(f, e.X); obj != nil && state.limited[obj] {
+				return "";
+			}
+			return "request body";
+		}
+	case *ast.CallExpr:
+		name := getCalleeName(f, e);
+		switch {
+		case boundingCalls[name]:
+			return "";
+		case decompressors[name]:
+			return "decompression reader";
+		case passThroughReaders[name] && len(e.Args) > 0:
+			return getStream(f, e.Args[0], state);
+		}
+	case *ast.UnaryExpr:
+		// &io.LimitedReader{}
+		return "";
+	}
+	if t := f.pkg.info.TypeOf(x); t != nil && connTypes[t.String()] {
+		return "network connection";
+	}
+	return "";
+}
+
+// trackStreams records the untrusted streams an assignment stores,
+// and requests whose Body is replaced with a bounded reader
+func trackStreams(f *File, lhs []ast.Expr, rhs []ast.Expr, state *streamState) {
+	for i, x := range lhs {
+		var value ast.Expr
+		switch {
+		case len(rhs) == len(lhs):
+			value = rhs[i];
+		case len(rhs) == 1 && i == 0:
+			// gz, err := gzip.NewReader(r)
+			value = rhs[0];
+		default:
+			continue;
+		}
+		// r.Body = http.MaxBytesReader(w, r.Body, n)
+		if sel, ok := x.(*ast.SelectorExpr); ok && sel.Sel.Name == "Body" {
+			if obj := getObject(f, sel.X); obj != nil {
+				state.limited[obj] = getStream(f, value, state) == "";
 			}
+			continue;
+		}
+		obj := getObject(f, x);
+		if obj == nil {
+			continue;
+		}
+		if kind := getStream(f, value, state); kind != "" {
+			state.streams[obj] = kind;
+		} else {
+			delete(state.streams, obj);
+		}
+	}
+}
+
+// checkReadAllSink reports a call that reads all of an untrusted stream
+func checkReadAllSink(f *File, call *ast.CallExpr, state *streamState) {
+	name := getCalleeName(f, call);
+	if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok && readAllMethods[name] {
+		if kind := getStream(f, sel.X, state); kind != "" {
+			reportUnbounded(f, call, kind);
+		}
+		return;
+	}
+	index, ok := readAllSinks[name];
+	if !ok || index >= len(call.Args) {
+		return;
+	}
+	kind := getStream(f, call.Args[index], state);
+	if kind == "" {
+		return;
+	}
+	// copying a stream somewhere other than memory only matters if it decompresses
+	if (name == "io.Copy" || name == "io.CopyBuffer") && kind != "decompression reader" {
+		if t := f.pkg.info.TypeOf(call.Args[0]); t == nil || !memoryWriters[t.String()] {
+			return;
 		}
 	}
-	return;
+	reportUnbounded(f, call, kind);
+}
+
+// reportUnbounded reports an unbounded read of a kind of stream
+func reportUnbounded(f *File, call *ast.CallExpr, kind string) {
+	if kind == "decompression reader" {
+		f.Reportf(call.Pos(), "decompression reader read without a limit, a small input can expand to fill memory or disk, use io.LimitReader or io.CopyN: %s", f.ASTString(call));
+		return;
+	}
+	f.Reportf(call.Pos(), "%s read without a limit, bound it with http.MaxBytesReader, io.LimitReader or io.CopyN: %s", kind, f.ASTString(call));
+}
+
+// readAllCheck looks for request bodies, network connections and
+// decompression readers that are read into memory, or decompressed,
+// without being bounded by http.MaxBytesReader, io.LimitReader or io.CopyN.
+// variables are followed in the order they are assigned
+func readAllCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
+	}
+	state := &streamState{
+		streams:	make(map[types.Object]string),
+		limited:	make(map[types.Object]bool),
+	}
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok == token.ASSIGN || x.Tok == token.DEFINE {
+				// the right hand side is read before the assignment
+				for _, rhs := range x.Rhs {
+					ast.Inspect(rhs, func(n ast.Node) bool {
+						if call, ok := n.(*ast.CallExpr); ok {
+							checkReadAllSink(f, call, state);
+						}
+						return true;
+					});
+				}
+				trackStreams(f, x.Lhs, x.Rhs, state);
+				return false;
+			}
+		case *ast.ValueSpec:
+			var lhs []ast.Expr
+			for _, name := range x.Names {
+				lhs = append(lhs, name);
+			}
+			trackStreams(f, lhs, x.Values, state);
+		case *ast.CallExpr:
+			checkReadAllSink(f, x, state);
+		}
+		return true;
+	});
 }
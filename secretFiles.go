@@ -0,0 +1,269 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxSecretFileSize keeps the scan away from large data files
+const maxSecretFileSize = 1 << 20
+
+// scannedFiles stops a file being scanned twice,
+// i.e. once in its directory and again as an embedded asset
+var scannedFiles = make(map[string]bool)
+
+// keyValueLine finds key=value and key: value pairs in .env, .properties and YAML files
+var keyValueLine = regexp.MustCompile(`^\s*(?:-\s+)?(?:export\s+)?["']?([A-Za-z0-9_.\-]+)["']?\s*[:=]\s*(.*)$`)
+
+// jsonPair finds "key": "value" pairs in JSON files
+var jsonPair = regexp.MustCompile(`"([^"]+)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+
+// dockerfileEnv finds ENV and ARG instructions in Dockerfiles
+var dockerfileEnv = regexp.MustCompile(`(?i)^\s*(?:ENV|ARG)\s+(.*)$`)
+
+// getSecretFileType returns the kind of file a path is, if it is one that is scanned for secrets
+func getSecretFileType(path string) string {
+	name := filepath.Base(path);
+	lower := strings.ToLower(name);
+	switch {
+	case lower == ".env", strings.HasPrefix(lower, ".env."), strings.HasSuffix(lower, ".env"):
+		return "env";
+	case strings.HasSuffix(lower, ".properties"):
+		return "env";
+	case strings.HasSuffix(lower, ".yaml"), strings.HasSuffix(lower, ".yml"):
+		return "yaml";
+	case strings.HasSuffix(lower, ".json"):
+		return "json";
+	case lower == "dockerfile", strings.HasPrefix(lower, "dockerfile."), strings.HasSuffix(lower, ".dockerfile"):
+		return "dockerfile";
+	}
+	return "";
+}
+
+// scanSecretDir scans the files in a directory that may hold secrets.
+// sub directories are reached through the directory walk.
+func scanSecretDir(dir string) {
+	entries, err := os.ReadDir(dir);
+	if err != nil {
+		if *verbose {
+			warnf("error reading directory %s, %s", dir, err);
+		}
+		return;
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue;
+		}
+		path := filepath.Join(dir, entry.Name());
+		if kind := getSecretFileType(path); kind != "" {
+			scanSecretFile(path, kind);
+		}
+	}
+}
+
+// scanEmbeddedFiles scans the files pulled in by //go:embed directives
+func scanEmbeddedFiles(name string, file *ast.File) {
+	dir := filepath.Dir(name);
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if !strings.HasPrefix(comment.Text, "//go:embed ") {
+				continue;
+			}
+			for _, pattern := range getEmbedPatterns(strings.TrimPrefix(comment.Text, "//go:embed ")) {
+				all := strings.HasPrefix(pattern, "all:");
+				pattern = strings.TrimPrefix(pattern, "all:");
+				matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)));
+				if err != nil {
+					continue;
+				}
+				for _, match := range matches {
+					scanEmbeddedPath(match, all);
+				}
+			}
+		}
+	}
+}
+
+// getEmbedPatterns splits the patterns of a //go:embed directive, some of which may be quoted
+func getEmbedPatterns(s string) []string {
+	var patterns []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' || s[0] == '`' {
+			if quoted, err := strconv.QuotedPrefix(s); err == nil {
+				pattern, _ := strconv.Unquote(quoted);
+				patterns = append(patterns, pattern);
+				s = s[len(quoted):];
+				continue;
+			}
+		}
+		end := strings.IndexAny(s, " \t");
+		if end < 0 {
+			end = len(s);
+		}
+		patterns = append(patterns, s[:end]);
+		s = s[end:];
+	}
+	return patterns;
+}
+
+// scanEmbeddedPath scans an embedded file, or every file in an embedded directory.
+// like go:embed, files starting with . or _ in a directory are left out unless all is set
+func scanEmbeddedPath(path string, all bool) {
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil;
+		}
+		base := info.Name();
+		if p != path && !all && (strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+			if info.IsDir() {
+				return filepath.SkipDir;
+			}
+			return nil;
+		}
+		if !info.IsDir() {
+			scanSecretFile(p, getSecretFileType(p));
+		}
+		return nil;
+	});
+}
+
+// getKeyValues returns the key and value pairs on a line of a file of the given kind
+func getKeyValues(kind string, line string) [][2]string {
+	var pairs [][2]string
+	switch kind {
+	case "json":
+		for _, m := range jsonPair.FindAllStringSubmatch(line, -1) {
+			val, err := strconv.Unquote(`"` + m[2] + `"`);
+			if err != nil {
+				val = m[2];
+			}
+			pairs = append(pairs, [2]string{m[1], val});
+		}
+		return pairs;
+	case "dockerfile":
+		m := dockerfileEnv.FindStringSubmatch(line);
+		if m == nil {
+			return nil;
+		}
+		// ENV KEY value, or ENV KEY=value KEY2=value2
+		fields := strings.Fields(m[1]);
+		if len(fields) >= 2 && !strings.Contains(fields[0], "=") {
+			return [][2]string{{fields[0], trimQuotes(strings.Join(fields[1:], " "))}};
+		}
+		for _, field := range fields {
+			if i := strings.Index(field, "="); i > 0 {
+				pairs = append(pairs, [2]string{field[:i], trimQuotes(field[i + 1:])});
+			}
+		}
+		return pairs;
+	}
+	trimmed := strings.TrimSpace(line);
+	if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+		return nil;
+	}
+	m := keyValueLine.FindStringSubmatch(line);
+	if m == nil {
+		return nil;
+	}
+	val := strings.TrimSpace(m[2]);
+	// trailing comments, but not # inside a quoted value
+	if !strings.HasPrefix(val, `"`) && !strings.HasPrefix(val, "'") {
+		if i := strings.Index(val, " #"); i >= 0 {
+			val = strings.TrimSpace(val[:i]);
+		}
+	}
+	return [][2]string{{m[1], trimQuotes(val)}};
+}
+
+// trimQuotes takes the quotes off a quoted value
+func trimQuotes(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s) - 1] == s[0] {
+		return s[1:len(s) - 1];
+	}
+	return s;
+}
+
+// scanSecretFile runs the hardcoded credential detectors over a file that isn't Go.
+// kind is the type of file, or empty for anything else, i.e. an embedded asset,
+// in which case only provider tokens and keys are looked for.
+// findings are reported the same way as for Go files.
+func scanSecretFile(path string, kind string) {
+	if scannedFiles[path] || matchesPath(path, config.Hardcoded.ExcludePaths) {
+		return;
+	}
+	scannedFiles[path] = true;
+	info, err := os.Stat(path);
+	if err != nil || info.Size() > maxSecretFileSize {
+		return;
+	}
+	data, err := os.ReadFile(path);
+	if err != nil {
+		if *verbose {
+			warnf("error reading %s, %s", path, err);
+		}
+		return;
+	}
+	// binary files
+	if bytes.IndexByte(data, 0) >= 0 {
+		return;
+	}
+	if *verbose {
+		warnf("Scanning %s for secrets", path);
+	}
+	// a file set lets findings be reported with Reportf like any other
+	fset := token.NewFileSet();
+	tf := fset.AddFile(path, -1, len(data));
+	tf.SetLinesForContent(data);
+	f := &File{
+		fset:	fset,
+		name:	path,
+	}
+	content := string(data);
+	for _, m := range findSecrets(content) {
+		if !isAllowedLit(m.value) {
+			f.Reportf(tf.Pos(m.offset), "Hardcoded %s found: %s", m.name, m);
+		}
+	}
+	if kind == "" {
+		return;
+	}
+	offset := 0;
+	for _, line := range strings.SplitAfter(content, "\n") {
+		pos := tf.Pos(offset);
+		offset += len(line);
+		for _, pair := range getKeyValues(kind, strings.TrimRight(line, "\r\n")) {
+			if desc, secret := checkKeyValue(f, pair[0], pair[1]); desc != "" {
+				f.Reportf(pos, "%s: %s", desc, secret);
+			}
+		}
+	}
+}
+
+// checkKeyValue runs the credential checks on a value and the key it is given.
+// it returns a description of what was found and the secret itself,
+// or an empty description if it doesn't look like a credential
+func checkKeyValue(f *File, key, val string) (string, string) {
+	// provider tokens and keys are found in the whole file, and ones that fail their checks aren't real
+	if isPlaceholder(val) || isAllowedLit(val) || matchesSecretPattern(val) {
+		return "", "";
+	}
+	if password, ok := getURLPassword(val); ok {
+		return "Hardcoded password in URL", password;
+	}
+	if password, ok := getDSNPassword(val); ok {
+		return "Hardcoded password in connection string", password;
+	}
+	if scoreCredential(f, key, val) >= config.Hardcoded.MinScore {
+		return "Possible credential found in " + key, val;
+	}
+	return "", "";
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"hash/crc32"
+	"regexp"
+	"strings"
+)
+
+// secretPattern recognises one kind of provider token or key
+// verify, if set, rules out matches that can't be real,
+// i.e. a bad checksum or a documented example value
+type secretPattern struct {
+	name	string
+	re	*regexp.Regexp
+	verify	func(string) bool
+}
+
+// secretMatch is a secret found in a string
+type secretMatch struct {
+	name	string
+	value	string
+	offset	int
+}
+
+var secretPatterns = []secretPattern{
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA|A3T[A-Z0-9])[A-Z2-7]{16}\b`), isAWSKeyID},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b`), isGitHubToken},
+	{"GitHub fine-grained token", regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9]{22}_[A-Za-z0-9]{59}\b`), nil},
+	{"GitLab token", regexp.MustCompile(`\bgl(?:pat|dt|ptt|rt)-[A-Za-z0-9_\-]{20,}`), nil},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[0-9]{10,13}-[0-9A-Za-z\-]{10,}`), nil},
+	{"Slack webhook", regexp.MustCompile(`https://hooks\.slack\.com/services/T[A-Z0-9]{8,}/B[A-Z0-9]{8,}/[A-Za-z0-9]{24}`), nil},
+	{"Stripe live key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`), nil},
+	{"Stripe test key", regexp.MustCompile(`\b[sr]k_test_[0-9A-Za-z]{24,}\b`), nil},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`), isJWT},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`), nil},
+	{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`), isPrivateKey},
+}
+
+// isAWSKeyID rules out the example key ID used throughout the AWS documentation
+func isAWSKeyID(s string) bool {
+	return !strings.HasSuffix(s, "EXAMPLE");
+}
+
+// isGitHubToken checks the checksum GitHub puts at the end of its tokens.
+// the last 6 characters are the base62 CRC32 of the 30 random ones before them
+func isGitHubToken(s string) bool {
+	body := s[strings.Index(s, "_") + 1:];
+	random, checksum := body[:30], body[30:];
+	return base62(crc32.ChecksumIEEE([]byte(random)), 6) == checksum;
+}
+
+// base62 encodes n with digits, then upper case, then lower case letters
+// and left pads it with zeros to width
+func base62(n uint32, width int) string {
+	const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz";
+	var buf []byte
+	for n > 0 {
+		buf = append([]byte{digits[n % 62]}, buf...);
+		n /= 62;
+	}
+	for len(buf) < width {
+		buf = append([]byte{'0'}, buf...);
+	}
+	return string(buf);
+}
+
+// isJWT checks that the header and claims decode to JSON
+// and that the header names a signing algorithm
+func isJWT(s string) bool {
+	parts := strings.Split(s, ".");
+	if len(parts) != 3 {
+		return false;
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	var claims map[string]interface{}
+	h, err := base64.RawURLEncoding.DecodeString(parts[0]);
+	if err != nil || json.Unmarshal(h, &header) != nil || header.Alg == "" {
+		return false;
+	}
+	c, err := base64.RawURLEncoding.DecodeString(parts[1]);
+	if err != nil || json.Unmarshal(c, &claims) != nil {
+		return false;
+	}
+	return true;
+}
+
+// isPrivateKey checks that a PEM block decodes to something
+func isPrivateKey(s string) bool {
+	block, _ := pem.Decode([]byte(s));
+	return block != nil && len(block.Bytes) != 0;
+}
+
+// findSecrets returns the provider tokens and private keys in a string
+func findSecrets(s string) []secretMatch {
+	var matches []secretMatch
+	for _, p := range secretPatterns {
+		for _, loc := range p.re.FindAllStringIndex(s, -1) {
+			value := s[loc[0]:loc[1]];
+			if p.verify != nil && !p.verify(value) {
+				continue;
+			}
+			matches = append(matches, secretMatch{name: p.name, value: value, offset: loc[0]});
+		}
+	}
+	return matches;
+}
+
+// matchesSecretPattern checks if a string has anything in the format of a provider
+// token or key in it, whether or not it passes its check. those that pass are
+// reported by findSecrets, those that fail are known not to be real
+func matchesSecretPattern(s string) bool {
+	for _, p := range secretPatterns {
+		if p.re.MatchString(s) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// String gives a form of the match that is safe to print.
+// private keys are cut down to their first line
+func (m secretMatch) String() string {
+	if i := strings.Index(m.value, "\n"); i >= 0 {
+		return m.value[:i] + "...";
+	}
+	return m.value;
+}
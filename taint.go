@@ -0,0 +1,294 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// taintSources are calls that return data controlled by whoever sent a request
+// this doesn't try to be exhaustive
+var taintSources = map[string]bool{
+	"(*net/http.Request).FormValue":	true,
+	"(*net/http.Request).PostFormValue":	true,
+	"(*net/http.Request).Cookie":		true,
+	"(*net/http.Request).Cookies":		true,
+	"(*net/http.Request).Referer":		true,
+	"(*net/http.Request).UserAgent":	true,
+	"(*net/http.Request).PathValue":	true,
+	"(net/url.Values).Get":			true,
+	"(net/http.Header).Get":		true,
+	"(net/http.Header).Values":		true,
+	"io/ioutil.ReadAll":			true,
+	"io.ReadAll":				true,
+}
+
+// isRequest checks if a type is a *http.Request
+func isRequest(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj();
+		return obj.Pkg() != nil && obj.Pkg().Path() == "net/http" && obj.Name() == "Request";
+	}
+	return false;
+}
+
+// isTaintableParam checks if a function parameter can carry user input.
+// Strings and byte slices are what usually do, requests carry everything else.
+func isTaintableParam(t types.Type) bool {
+	if isRequest(t) {
+		return true;
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return u.Kind() == types.String;
+	case *types.Slice:
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok {
+			return b.Kind() == types.Byte || b.Kind() == types.String;
+		}
+	}
+	return false;
+}
+
+// isPassedData checks if any caller in the package gives the parameter at index
+// something other than a constant. A function used as a value, i.e. a handler
+// or callback, could be given anything, so its parameters count as passed data.
+func isPassedData(f *File, fn *types.Func, index int) bool {
+	sig, ok := fn.Type().(*types.Signature);
+	if !ok {
+		return true;
+	}
+	called := make(map[*ast.Ident]bool);
+	found := false;
+	for _, file := range f.pkg.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr);
+			if !ok || found || getCalledFunc(f, call) != fn {
+				return !found;
+			}
+			switch x := unparen(call.Fun).(type) {
+			case *ast.Ident:
+				called[x] = true;
+			case *ast.SelectorExpr:
+				called[x.Sel] = true;
+			}
+			args := call.Args;
+			switch {
+			case len(args) == 1 && sig.Params().Len() > 1:
+				// f(g()) with g returning several values
+				found = true;
+			case sig.Variadic() && index == sig.Params().Len() - 1 && index < len(args):
+				for _, arg := range args[index:] {
+					found = found || !isConstExpr(f, arg);
+				}
+			case index < len(args):
+				found = !isConstExpr(f, args[index]);
+			}
+			return !found;
+		});
+	}
+	if found {
+		return true;
+	}
+	for id, obj := range f.pkg.info.Uses {
+		if obj == fn && !called[id] {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isConstExpr checks if an expression has a constant value, i.e. "page" or "a" + "b"
+func isConstExpr(f *File, x ast.Expr) bool {
+	tv, ok := f.pkg.info.Types[x];
+	return ok && tv.Value != nil;
+}
+
+// getTainted returns the set of variables in a function that may hold
+// tainted data. Requests start out tainted, and so do string and byte slice
+// parameters that a caller in the package gives something other than a constant.
+// Anything assigned from a tainted expression is tainted too.
+func getTainted(f *File, fun *ast.FuncDecl) map[types.Object]bool {
+	tainted := make(map[types.Object]bool);
+	fn, _ := f.pkg.info.Defs[fun.Name].(*types.Func);
+	if fun.Type.Params != nil {
+		index := 0;
+		for _, field := range fun.Type.Params.List {
+			names := field.Names;
+			if len(names) == 0 {
+				index++;
+			}
+			for _, name := range names {
+				obj := f.pkg.info.Defs[name];
+				if obj != nil && isTaintableParam(obj.Type()) && (isRequest(obj.Type()) || fn == nil || isPassedData(f, fn, index)) {
+					tainted[obj] = true;
+				}
+				index++;
+			}
+		}
+	}
+	if fun.Body == nil {
+		return tainted;
+	}
+	spreadTaint(f, fun.Body, tainted, taintSources);
+	return tainted;
+}
+
+// spreadTaint adds anything assigned from a tainted expression in body to tainted
+// calls named in sources are tainted wherever they appear
+func spreadTaint(f *File, body ast.Node, tainted map[types.Object]bool, sources map[string]bool) {
+	// keep going until nothing new is tainted
+	// assignments can appear in any order relative to their uses in loops
+	for changed := true; changed; {
+		changed = false;
+		ast.Inspect(body, func(n ast.Node) bool {
+			var lhs, rhs []ast.Expr
+			switch stmt := n.(type) {
+			case *ast.AssignStmt:
+				lhs, rhs = stmt.Lhs, stmt.Rhs;
+			case *ast.ValueSpec:
+				for _, name := range stmt.Names {
+					lhs = append(lhs, name);
+				}
+				rhs = stmt.Values;
+			case *ast.RangeStmt:
+				if stmt.Key != nil {
+					lhs = append(lhs, stmt.Key);
+				}
+				if stmt.Value != nil {
+					lhs = append(lhs, stmt.Value);
+				}
+				rhs = []ast.Expr{stmt.X};
+			default:
+				return true;
+			}
+			for i, l := range lhs {
+				// a, b := f() taints everything on the left
+				var r ast.Expr
+				if len(rhs) == len(lhs) {
+					r = rhs[i];
+				} else if len(rhs) > 0 {
+					r = rhs[0];
+				}
+				if r == nil || !usesTainted(f, r, tainted, sources) {
+					continue;
+				}
+				if obj := getObject(f, l); obj != nil && !tainted[obj] {
+					tainted[obj] = true;
+					changed = true;
+				}
+			}
+			return true;
+		});
+	}
+}
+
+// getObject returns the variable an assignment target refers to
+func getObject(f *File, x ast.Expr) types.Object {
+	switch e := x.(type) {
+	case *ast.Ident:
+		if obj := f.pkg.info.Defs[e]; obj != nil {
+			return obj;
+		}
+		return f.pkg.info.Uses[e];
+	case *ast.ParenExpr:
+		return getObject(f, e.X);
+	case *ast.StarExpr:
+		return getObject(f, e.X);
+	case *ast.IndexExpr:
+		return getObject(f, e.X);
+	}
+	return nil;
+}
+
+// isTaintedExpr checks if an expression contains a tainted variable
+// or a call that returns user controlled data
+func isTaintedExpr(f *File, x ast.Expr, tainted map[types.Object]bool) bool {
+	return usesTainted(f, x, tainted, taintSources);
+}
+
+// usesTainted checks if an expression contains a tainted variable or a call named in sources
+func usesTainted(f *File, x ast.Expr, tainted map[types.Object]bool, sources map[string]bool) bool {
+	// constants can't be tainted no matter what they are built from
+	if tv, ok := f.pkg.info.Types[x]; ok && tv.Value != nil {
+		return false;
+	}
+	found := false;
+	ast.Inspect(x, func(n ast.Node) bool {
+		if found {
+			return false;
+		}
+		switch e := n.(type) {
+		case *ast.Ident:
+			if obj := f.pkg.info.Uses[e]; obj != nil && tainted[obj] {
+				found = true;
+			}
+		case *ast.CallExpr:
+			if sources[getCalleeName(f, e)] {
+				found = true;
+			}
+		case *ast.FuncLit:
+			// a closure isn't data
+			return false;
+		}
+		return !found;
+	});
+	return found;
+}
+
+// getBufferVar returns the variable a buffer expression refers to
+// i.e. buf, buf[:12] or &buf
+func getBufferVar(f *File, x ast.Expr) types.Object {
+	switch e := x.(type) {
+	case *ast.Ident:
+		if v, ok := f.pkg.info.Uses[e].(*types.Var); ok {
+			return v;
+		}
+	case *ast.SliceExpr:
+		return getBufferVar(f, e.X);
+	case *ast.ParenExpr:
+		return getBufferVar(f, e.X);
+	case *ast.UnaryExpr:
+		return getBufferVar(f, e.X);
+	}
+	return nil;
+}
+
+// getResultVars returns the variables the result of a call is stored in.
+// calls named Read fill their arguments so those count too, i.e. buf in rand.Read(buf)
+func getResultVars(f *File, call *ast.CallExpr) []types.Object {
+	var vars []types.Object
+	if getFuncName(call) == "Read" {
+		for _, arg := range call.Args {
+			if obj := getBufferVar(f, arg); obj != nil {
+				vars = append(vars, obj);
+			}
+		}
+	}
+	path, _ := astutil.PathEnclosingInterval(f.file, call.Pos(), call.End());
+	for _, n := range path {
+		if assign, ok := n.(*ast.AssignStmt); ok {
+			for _, lhs := range assign.Lhs {
+				if obj := getObject(f, lhs); obj != nil {
+					vars = append(vars, obj);
+				}
+			}
+			break;
+		}
+		if spec, ok := n.(*ast.ValueSpec); ok {
+			for _, name := range spec.Names {
+				if obj := f.pkg.info.Defs[name]; obj != nil {
+					vars = append(vars, obj);
+				}
+			}
+			break;
+		}
+	}
+	return vars;
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+func init() {
+	register("templateInjection",
+		"this checks for templates parsed from tainted input and dangerous template functions",
+		templateInjectionCheck,
+		funcDecl, compositeLit)
+}
+
+// templateParsers maps template parsing functions to what their arguments are
+var templateParsers = map[string]string{
+	"(*text/template.Template).Parse":	"text",
+	"(*html/template.Template).Parse":	"text",
+	"text/template.ParseFiles":		"path",
+	"html/template.ParseFiles":		"path",
+	"(*text/template.Template).ParseFiles":	"path",
+	"(*html/template.Template).ParseFiles":	"path",
+	"text/template.ParseGlob":		"path",
+	"html/template.ParseGlob":		"path",
+	"(*text/template.Template).ParseGlob":	"path",
+	"(*html/template.Template).ParseGlob":	"path",
+}
+
+// dangerousTemplatePkgs are packages that should never be reachable from a template
+var dangerousTemplatePkgs = map[string]bool{
+	"os/exec":	true,
+	"syscall":	true,
+	"plugin":	true,
+	"unsafe":	true,
+}
+
+// dangerousTemplateFuncs are single functions that should never be reachable from a template
+var dangerousTemplateFuncs = map[string]bool{
+	"os.Create":		true,
+	"os.Getenv":		true,
+	"os.Open":		true,
+	"os.OpenFile":		true,
+	"os.ReadFile":		true,
+	"os.Remove":		true,
+	"os.RemoveAll":		true,
+	"os.Rename":		true,
+	"os.Setenv":		true,
+	"os.StartProcess":	true,
+	"os.WriteFile":		true,
+	"os.Exit":		true,
+}
+
+// isDangerousFunc checks if a function is dangerous to expose to a template
+func isDangerousFunc(fn *types.Func) bool {
+	if fn.Pkg() == nil {
+		return false;
+	}
+	return dangerousTemplatePkgs[fn.Pkg().Path()] || dangerousTemplateFuncs[fn.FullName()];
+}
+
+// callsDangerousFunc looks through a function body for calls to dangerous functions
+// functions declared in the package are followed, seen prevents endless recursion
+func callsDangerousFunc(f *File, body ast.Node, seen map[*types.Func]bool) (string, bool) {
+	var name string
+	found := false;
+	ast.Inspect(body, func(n ast.Node) bool {
+		if found {
+			return false;
+		}
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		fn := getCalledFunc(f, call);
+		if fn == nil {
+			return true;
+		}
+		if isDangerousFunc(fn) {
+			name, found = fn.FullName(), true;
+			return false;
+		}
+		if seen[fn] {
+			return true;
+		}
+		seen[fn] = true;
+		if decl := getFuncDecl(f, fn); decl != nil && decl.Body != nil {
+			name, found = callsDangerousFunc(f, decl.Body, seen);
+		}
+		return !found;
+	});
+	return name, found;
+}
+
+// exposesDangerousFunc checks a value placed in a template.FuncMap
+func exposesDangerousFunc(f *File, x ast.Expr) (string, bool) {
+	var id *ast.Ident
+	switch v := x.(type) {
+	case *ast.FuncLit:
+		return callsDangerousFunc(f, v.Body, make(map[*types.Func]bool));
+	case *ast.Ident:
+		id = v;
+	case *ast.SelectorExpr:
+		id = v.Sel;
+	default:
+		return "", false;
+	}
+	fn, ok := f.pkg.info.Uses[id].(*types.Func);
+	if !ok {
+		return "", false;
+	}
+	if isDangerousFunc(fn) {
+		return fn.FullName(), true;
+	}
+	if decl := getFuncDecl(f, fn); decl != nil && decl.Body != nil {
+		return callsDangerousFunc(f, decl.Body, map[*types.Func]bool{fn: true});
+	}
+	return "", false;
+}
+
+// isFuncMap checks if a composite literal is a text/template or html/template FuncMap
+func isFuncMap(f *File, lit *ast.CompositeLit) bool {
+	// html/template.FuncMap is an alias of text/template.FuncMap
+	named, ok := types.Unalias(f.pkg.info.TypeOf(lit)).(*types.Named);
+	if !ok {
+		return false;
+	}
+	obj := named.Obj();
+	if obj.Pkg() == nil || obj.Name() != "FuncMap" {
+		return false;
+	}
+	path := obj.Pkg().Path();
+	return path == "text/template" || path == "html/template";
+}
+
+func checkFuncMap(f *File, lit *ast.CompositeLit) {
+	if !isFuncMap(f, lit) {
+		return;
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if name, ok := exposesDangerousFunc(f, kv.Value); ok {
+				f.Reportf(kv.Pos(), "template function exposes %s to templates: %s", name, f.ASTString(kv.Key));
+			}
+		}
+	}
+}
+
+func checkTemplateParse(f *File, fun *ast.FuncDecl) {
+	if fun.Body == nil {
+		return;
+	}
+	var tainted map[types.Object]bool
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		kind, ok := templateParsers[getCalleeName(f, call)];
+		if !ok {
+			return true;
+		}
+		// only work out what is tainted once a template is actually parsed
+		if tainted == nil {
+			tainted = getTainted(f, fun);
+		}
+		for _, arg := range call.Args {
+			if isTaintedExpr(f, arg, tainted) {
+				if kind == "text" {
+					f.Reportf(call.Pos(), "template text from tainted input, possible template injection: %s", f.ASTString(call));
+				} else {
+					f.Reportf(call.Pos(), "template path from tainted input, possible template injection: %s", f.ASTString(call));
+				}
+				break;
+			}
+		}
+		return true;
+	});
+}
+
+func templateInjectionCheck(f *File, node ast.Node) {
+	switch t := node.(type) {
+	case *ast.FuncDecl:
+		checkTemplateParse(f, t);
+	case *ast.CompositeLit:
+		checkFuncMap(f, t);
+	}
+	return;
+}
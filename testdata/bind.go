@@ -1,10 +1,14 @@
 package main
 
 import (
-	"net"
 	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
 )
 
+const adminAddr = ":9090"
+
 func bindAll() {
 	tcpListener, err := net.Listen("tcp", "0.0.0.0:80");
 	tlsListener, err := tls.Listen("tcp", "0.0.0.0:443", nil);
@@ -16,3 +20,49 @@ func bindAll() {
 		tcpListener = nil;
 	}
 }
+
+// bad
+func bindAll2(port string) {
+	net.Listen("tcp", ":8080")
+	net.Listen("tcp6", "[::]:80")
+	net.ListenPacket("udp", ":53")
+	http.ListenAndServe(":80", nil)
+	net.Listen("tcp", adminAddr)
+	net.Listen("tcp", net.JoinHostPort("", port))
+	net.Listen("tcp", fmt.Sprintf(":%s", port))
+	net.Listen("tcp", ":"+port)
+	addr := "0.0.0.0:" + port
+	net.Listen("tcp", addr)
+	net.ListenTCP("tcp", nil)
+	net.ListenTCP("tcp", &net.TCPAddr{Port: 80})
+	net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 53})
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", ":80")
+	net.ListenTCP("tcp", tcpAddr)
+	srv := &http.Server{Addr: ":8443"}
+	srv.ListenAndServeTLS("cert.pem", "key.pem")
+}
+
+// bad, an empty address listens on every interface
+func bindEmpty() {
+	http.ListenAndServe("", nil)
+	net.Listen("tcp", "")
+	srv := &http.Server{}
+	srv.ListenAndServe()
+}
+
+// good
+func bindLocal(host, port string) {
+	net.Listen("tcp", "127.0.0.1:8080")
+	net.Listen("tcp", "[::1]:80")
+	net.Listen("tcp", net.JoinHostPort("localhost", port))
+	net.Listen("tcp", net.JoinHostPort(host, port))
+	net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 80})
+	http.ListenAndServe("localhost:80", nil)
+}
+
+// good, Serve ignores Addr and the listener is local
+func bindServe() {
+	srv := &http.Server{}
+	ln, _ := net.Listen("tcp", "127.0.0.1:8080")
+	srv.Serve(ln)
+}
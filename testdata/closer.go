@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+type holder struct {
+	f *os.File
+}
+
+// good, deferred
+func closer1(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+// bad, leaked on the early return
+func closer2(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return errors.New("empty")
+	}
+	return f.Close()
+}
+
+// good, returned to the caller
+func closer3(name string) (*os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// good, closed in a helper
+func closer4(name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	closeQuietly(f)
+}
+
+func closeQuietly(c io.Closer) {
+	c.Close()
+}
+
+// good, kept in a struct
+func closer5(h *holder, name string) {
+	f, _ := os.Open(name)
+	h.f = f
+}
+
+// bad, the body is never closed
+func closer6(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// good
+func closer7(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// bad, rows leak
+func closer8(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, nil
+}
+
+// good, closed in a deferred closure
+func closer9(name string) {
+	f, err := os.Create(name)
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+	}()
+	f.WriteString("x")
+}
+
+// bad, only read from
+func closer10(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// bad
+func cookies1(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:	"session_id",
+		Value:	token,
+		MaxAge:	365 * 24 * 60 * 60,
+	})
+}
+
+// good
+func cookies2(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:		"session_id",
+		Value:		token,
+		MaxAge:		60 * 60,
+		Secure:		true,
+		HttpOnly:	true,
+		SameSite:	http.SameSiteLaxMode,
+	})
+}
+
+// bad
+func cookies3(w http.ResponseWriter, token string) {
+	c := new(http.Cookie)
+	c.Name = "auth"
+	c.Value = token
+	c.Secure = true
+	http.SetCookie(w, c)
+}
+
+// bad, SameSiteDefaultMode leaves it to the browser
+func cookies4(w http.ResponseWriter) {
+	var c http.Cookie
+	c.Name = "theme"
+	c.Secure = true
+	c.HttpOnly = true
+	c.SameSite = http.SameSiteDefaultMode
+	c.Expires = time.Now().Add(time.Hour)
+	http.SetCookie(w, &c)
+}
+
+// bad
+var store = sessions.NewCookieStore([]byte("super-secret-key"))
+
+// bad
+func cookies5() *sessions.CookieStore {
+	return sessions.NewCookieStore(securecookie.GenerateRandomKey(16))
+}
+
+// good
+func cookies6() *sessions.CookieStore {
+	return sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+}
+
+// good, cookies sent by a client
+func cookies7(req *http.Request, jar http.CookieJar, token string) {
+	req.AddCookie(&http.Cookie{Name: "session", Value: token})
+	jar.SetCookies(req.URL, []*http.Cookie{{Name: "session", Value: token}})
+}
+
+// bad, set through the header
+func cookies8(w http.ResponseWriter, token string) {
+	c := &http.Cookie{Name: "inside", Value: token, Secure: true, HttpOnly: true}
+	w.Header().Add("Set-Cookie", c.String())
+}
+
+// good, author isn't auth
+func cookies9(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:		"author",
+		Value:		name,
+		MaxAge:		365 * 24 * 60 * 60,
+		Secure:		true,
+		HttpOnly:	true,
+		SameSite:	http.SameSiteLaxMode,
+	})
+}
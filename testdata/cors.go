@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gorilla/websocket"
+	rscors "github.com/rs/cors"
+)
+
+// bad
+func cors1(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+}
+
+// bad
+func cors2(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// bad
+func cors3(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+}
+
+var allowedOrigins = map[string]bool{"https://example.com": true}
+
+// good
+func cors4(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if allowedOrigins[origin] {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// good, no credentials
+func cors5(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+}
+
+// bad
+var corsHandler = rscors.New(rscors.Options{
+	AllowedOrigins:		[]string{"*"},
+	AllowCredentials:	true,
+})
+
+// bad
+var ginCors = cors.New(cors.Config{
+	AllowAllOrigins:	true,
+	AllowCredentials:	true,
+})
+
+// good
+var ginCors2 = cors.New(cors.Config{
+	AllowOrigins:		[]string{"https://example.com"},
+	AllowCredentials:	true,
+})
+
+// bad
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// good
+var upgrader2 = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return allowedOrigins[r.Header.Get("Origin")]
+	},
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+func step(n int) error {
+	if n > 1 {
+		return errors.New("step")
+	}
+	return nil
+}
+
+// bad, the first error is overwritten
+func deadError1() error {
+	err := step(1)
+	err = step(2)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// bad, the Atoi error is dropped when check is false and overwritten when it is true
+func deadError2(s string, check bool) int {
+	n, err := strconv.Atoi(s)
+	if check {
+		err = step(n)
+		if err != nil {
+			return 0
+		}
+	}
+	return n
+}
+
+// good
+func deadError3() error {
+	err := step(1)
+	if err != nil {
+		return err
+	}
+	err = step(2)
+	return err
+}
+
+// bad, the outer err is always nil when it is returned
+func deadError4(xs []string) (int, error) {
+	var err error
+	total := 0
+	for _, x := range xs {
+		n, err := strconv.Atoi(x)
+		if err != nil {
+			break
+		}
+		total += n
+	}
+	return total, err
+}
+
+// good, the usual form
+func deadError5(xs []string) error {
+	var err error
+	for _, x := range xs {
+		if _, err := strconv.Atoi(x); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// good, only overwritten on one path and checked after
+func deadError6(retry bool) error {
+	err := step(1)
+	if retry {
+		err = step(2)
+	}
+	return err
+}
+
+// bad, the second error is never checked
+func deadError7() error {
+	err := step(1)
+	if err != nil {
+		return err
+	}
+	err = step(2)
+	return nil
+}
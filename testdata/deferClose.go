@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// bad
+func deferClose1(names []string) {
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		fmt.Println(f.Name())
+	}
+}
+
+// bad
+func deferClose2(urls []string) {
+	for i := 0; i < len(urls); i++ {
+		resp, err := http.Get(urls[i])
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+	}
+}
+
+// good, closed when each function literal returns
+func deferClose3(names []string) {
+	for _, name := range names {
+		func() {
+			f, err := os.Open(name)
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			fmt.Println(f.Name())
+		}()
+	}
+}
+
+// bad
+func deferClose4(name string, data []byte) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+// bad
+func deferClose5(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, err = f.Write(data)
+	return err
+}
+
+// good, read only
+func deferClose6(name string) error {
+	f, err := os.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+// good, the Close error is returned
+func deferClose7(name string, data []byte) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = f.Write(data)
+	return err
+}
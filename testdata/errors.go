@@ -1,7 +1,13 @@
 package main
 
 import(
+	"bytes"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
 )
 
 // this function returns an error
@@ -65,3 +71,97 @@ func errorsTest() int {
 	} 
 
 }
+
+type myErr struct {
+	msg string
+}
+
+func (e *myErr) Error() string {
+	return e.msg
+}
+
+type codeErr int
+
+func (e codeErr) Error() string {
+	return "code"
+}
+
+func retMyErr() *myErr {
+	return &myErr{"my error"}
+}
+
+func retCodeErr() (int, codeErr) {
+	return 0, codeErr(1)
+}
+
+type closer struct{}
+
+func (c closer) Flush() error {
+	return nil
+}
+
+func errorsTest2() {
+	var c closer
+
+	// bad
+	retMyErr()
+
+	// bad
+	a, _ := retCodeErr()
+
+	// bad
+	_ = retMyErr()
+
+	// bad
+	go retError1(a)
+
+	// bad
+	defer c.Flush()
+
+	// bad
+	flush := c.Flush
+	flush()
+
+	// bad
+	_, b := retMyErr(), a
+	_ = b
+
+	// good
+	if err := flush(); err != nil {
+		return
+	}
+
+	// good, a conversion not a call
+	_ = codeErr(a)
+}
+
+func Sprintf(format string, a ...interface{}) error {
+	return errors.New(fmt.Sprintf(format, a...))
+}
+
+func errorsTest3() {
+	var buf bytes.Buffer
+	var sb strings.Builder
+	h := sha256.New()
+
+	// good, these never fail
+	buf.Write([]byte("a"))
+	buf.WriteString("a")
+	sb.WriteString("a")
+	h.Write([]byte("a"))
+	fmt.Fprintf(&buf, "%d", 1)
+	fmt.Println("a")
+
+	// bad, not fmt's print
+	Sprintf("%d", 1)
+
+	// good, stdout and stderr are printed to as freely as fmt.Println
+	fmt.Fprintf(os.Stdout, "%d", 1)
+	fmt.Fprintln(os.Stderr, "a")
+	os.Stdout.WriteString("a")
+}
+
+// good, unless run with -verbose
+func errorsTest4(w http.ResponseWriter) {
+	fmt.Fprintln(w, "a")
+}
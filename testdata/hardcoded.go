@@ -43,3 +43,15 @@ func hardcoded2() {
 
 	fmt.Println(notACred1, notACred2, notACred3, errorStr);
 }
+
+// good, named like credentials but nothing about the values says they are one
+const tokenTTL = 3600;
+const maxPasswordLen = 72;
+const tokenURL = "https://oauth2.example.com/token";
+
+func hardcoded3() {
+	tokenType := "Bearer";
+	passwordPrompt := "Enter password:";
+	passwordPath := "/etc/app/password";
+	fmt.Println(tokenType, passwordPrompt, passwordPath, tokenTTL, maxPasswordLen, tokenURL);
+}
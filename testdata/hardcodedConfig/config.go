@@ -0,0 +1,19 @@
+package main
+
+// run with -config testdata/hardcodedConfig/glasgo.json
+
+// good, matches the allow pattern
+const defaultPassword = "changeme-1234"
+
+// bad, doesn't match the allow pattern
+const adminPassword = "changeme-now"
+
+// good, prefix scores the name down
+const keyPrefix = "k9X2mQ7vR4tL8wP3"
+
+// bad, the same value under a name that sometimes holds credentials
+const signingKey = "k9X2mQ7vR4tL8wP3"
+
+func main() {
+	println(defaultPassword, adminPassword, keyPrefix, signingKey)
+}
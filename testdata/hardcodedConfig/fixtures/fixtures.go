@@ -0,0 +1,4 @@
+package fixtures
+
+// good, fixtures/* is in excludePaths
+const Password = "hunter2hunter2"
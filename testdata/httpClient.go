@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// bad
+func httpClient1(url string) (*http.Response, error) {
+	return http.Get(url)
+}
+
+// bad
+func httpClient2(req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req)
+}
+
+// bad
+func httpClient3(req *http.Request) (*http.Response, error) {
+	client := &http.Client{}
+	return client.Do(req)
+}
+
+// good
+func httpClient4(req *http.Request) (*http.Response, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:		(&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+			TLSHandshakeTimeout:	5 * time.Second,
+		},
+	}
+	return client.Do(req)
+}
+
+// good, the client's Timeout bounds dialing and the handshake too
+func httpClient5() *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{KeepAlive: time.Minute}).DialContext,
+		},
+	}
+}
+
+// bad, the dialer has no timeout and neither does the handshake
+func httpClient5b() *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{KeepAlive: time.Minute}).DialContext,
+	}
+}
+
+// bad
+func httpClient6(token string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			req.Header.Set("Authorization", via[0].Header.Get("Authorization"))
+			return nil
+		},
+	}
+}
+
+// good, only sent on to the same host
+func httpClient7(token string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Host == via[0].URL.Host {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return nil
+		},
+	}
+}
+
+// bad
+func copyHeaders(req *http.Request, via []*http.Request) error {
+	for k, v := range via[0].Header {
+		req.Header[k] = v
+	}
+	return nil
+}
+
+func httpClient8() *http.Client {
+	c := &http.Client{Timeout: time.Minute}
+	c.CheckRedirect = copyHeaders
+	return c
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// bad
+func httpServer1() error {
+	return http.ListenAndServe("localhost:8080", nil)
+}
+
+// bad
+func httpServer2() error {
+	return http.ListenAndServeTLS("localhost:8443", "cert.pem", "key.pem", nil)
+}
+
+// bad
+func httpServer3() error {
+	srv := &http.Server{
+		Addr:		"localhost:8080",
+		WriteTimeout:	10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+// good
+func httpServer4() error {
+	srv := &http.Server{
+		Addr:			"localhost:8080",
+		ReadHeaderTimeout:	5 * time.Second,
+		WriteTimeout:		10 * time.Second,
+		IdleTimeout:		time.Minute,
+		MaxHeaderBytes:		1 << 16,
+	}
+	return srv.ListenAndServe()
+}
+
+// good, fields are set after the literal and ReadTimeout covers IdleTimeout
+func httpServer5() error {
+	var srv = http.Server{Addr: "localhost:8080"}
+	srv.ReadTimeout = 5 * time.Second
+	srv.WriteTimeout = 10 * time.Second
+	srv.MaxHeaderBytes = 1 << 16
+	return srv.ListenAndServe()
+}
+
+// bad, zero is no timeout
+func httpServer6() error {
+	srv := http.Server{
+		ReadTimeout:	0,
+		WriteTimeout:	time.Second,
+		MaxHeaderBytes:	1 << 16,
+	}
+	return srv.ListenAndServe()
+}
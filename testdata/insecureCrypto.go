@@ -1,11 +1,25 @@
 package main
 
 import(
+	"crypto"
+	"crypto/aes"
 	"crypto/des"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha1"
+	"encoding/hex"
+
+	"golang.org/x/crypto/md4"
 )
 
+// bad, package level
+var hasher = md5.New();
+
+// audit, only used for a checksum
+var checksum = sha1.New();
+
 func badCrypto() int {
 	var key []byte;
 	h := md5.New();
@@ -19,3 +33,67 @@ func badCrypto() int {
 	}
 	return 0;
 }
+
+// only an audit, git uses sha1 for object ids
+func objectID(data []byte) string {
+	sum := sha1.Sum(data);
+	return hex.EncodeToString(sum[:]);
+}
+
+// bad, reported once by passwordStorage
+func hashPassword(password string) string {
+	sum := md5.Sum([]byte(password));
+	return hex.EncodeToString(sum[:]);
+}
+
+func storeCredentials(user, pass string) []byte {
+	h := md4.New();
+	h.Write([]byte(pass));
+	passwordHash := h.Sum(nil);
+	return passwordHash;
+}
+
+func macMessage(key, msg []byte) []byte {
+	mac := hmac.New(sha1.New, key);
+	mac.Write(msg);
+	return mac.Sum(nil);
+}
+
+func signMessage(priv *rsa.PrivateKey, msg []byte) ([]byte, error) {
+	digest := md5.Sum(msg);
+	return rsa.SignPKCS1v15(rand.Reader, priv, crypto.MD5, digest[:]);
+}
+
+func newSessionToken(seed []byte) string {
+	sum := sha1.Sum(seed);
+	token := hex.EncodeToString(sum[:]);
+	return token;
+}
+
+// audit, the function name doesn't say what the hash is for
+func resetCache(data []byte) string {
+	sum := md5.Sum(data);
+	return hex.EncodeToString(sum[:]);
+}
+
+func hashPasswords(passwords []string) {
+	for _, password := range passwords {
+		hasher.Write([]byte(password));
+	}
+	checksum.Write([]byte("data"));
+}
+
+func encryptBlocks(key, data []byte) {
+	block, _ := aes.NewCipher(key);
+	block.Encrypt(data, data);
+}
+
+// audit only, none of these names say what the hashes are for
+func misleadingNames(data []byte) {
+	unsigned := md5.Sum(data);
+	pwd := sha1.Sum(data);
+	tokenizer := md5.New();
+	footprint := sha1.Sum(data);
+	isMac := md5.Sum(data);
+	_, _, _, _, _ = unsigned, pwd, tokenizer, footprint, isMac;
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"os"
 )
 
 func insecureTLS() {
@@ -15,3 +17,42 @@ func insecureTLS() {
 
 	tls.Dial("tcp", "mail.google.com:443", config);
 }
+
+const minVersion = tls.VersionTLS10
+
+func insecureTLS2(keyLog *os.File) *tls.Config {
+	config := &tls.Config{
+		MinVersion:		minVersion,
+		MaxVersion:		0x0302,
+		Renegotiation:		tls.RenegotiateFreelyAsClient,
+		ClientAuth:		tls.RequireAnyClientCert,
+		KeyLogWriter:		keyLog,
+		CurvePreferences:	[]tls.CurveID{tls.X25519, 0x0015},
+		VerifyPeerCertificate:	func(raw [][]byte, chains [][]*x509.Certificate) error {
+			return nil;
+		},
+	};
+	config.InsecureSkipVerify = true;
+	config.MinVersion = tls.VersionTLS11;
+	config.CipherSuites = []uint16{
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	};
+	return config;
+}
+
+// not a tls.Config, shouldn't be reported
+type options struct {
+	InsecureSkipVerify	bool
+	MinVersion		uint16
+}
+
+func secureTLS() (*tls.Config, options) {
+	config := &tls.Config{
+		MinVersion:		tls.VersionTLS12,
+		ClientAuth:		tls.RequireAndVerifyClientCert,
+		KeyLogWriter:		nil,
+	};
+	return config, options{InsecureSkipVerify: true, MinVersion: 0};
+}
@@ -5,6 +5,7 @@ import(
 	"strings"
 )
 
+// good, a strings.Reader isn't untrusted input
 func testReadAll() string {
 	r := strings.NewReader("this is a test for use of ioutil.ReadAll");
 
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type User struct {
+	Name		string
+	Password	string
+}
+
+// bad, fast and unsalted
+func hashPassword1(password string) [32]byte {
+	return sha256.Sum256([]byte(password))
+}
+
+// bad, fast but salted
+func hashPassword2(password string, salt []byte) [64]byte {
+	return sha512.Sum512(append(salt, password...))
+}
+
+// bad, fast and unsalted
+func hashPassword3(u *User) []byte {
+	h := sha256.New()
+	h.Write([]byte(u.Password))
+	return h.Sum(nil)
+}
+
+// good
+func hashPassword4(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// bad, plaintext
+func saveUser(db *sql.DB, u *User) error {
+	_, err := db.Exec("INSERT INTO users (name, password) VALUES (?, ?)", u.Name, u.Password)
+	return err
+}
+
+// good
+func saveUserHashed(db *sql.DB, u *User) error {
+	passwordHash, err := hashPassword4(u.Password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO users (name, password) VALUES (?, ?)", u.Name, passwordHash)
+	return err
+}
+
+// good, only a lookup
+func findUser(db *sql.DB, name, password string) (*sql.Rows, error) {
+	return db.Query("SELECT * FROM users WHERE name = ?", name)
+}
+
+// bad, plaintext
+func writeCredentials(file *os.File, user, password string) {
+	fmt.Fprintf(file, "%s:%s\n", user, password)
+	os.WriteFile("creds.txt", []byte(password), 0600)
+}
+
+// good, pwd is the working directory
+func saveWorkingDir(path string) {
+	pwd, _ := os.Getwd()
+	os.WriteFile(path, []byte(pwd), 0600)
+}
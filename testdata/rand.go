@@ -1,12 +1,105 @@
 package main
 
 import(
-	"math/rand"
+	"crypto/aes"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"math/rand/v2"
+	"strconv"
+	"time"
 )
 
+type Session struct {
+	ID	string
+	Expires	time.Time
+}
+
 func insecureRand() int {
 	// there are many possible uses for math/rand
 	// it's impractical to check for every possible use
 	
-	return rand.Int();
+	return mrand.Int();
+}
+
+// fine, jitter doesn't need to be unpredictable
+func backoff(attempt int) time.Duration {
+	jitter := rand.IntN(100)
+	return time.Duration(attempt*100+jitter) * time.Millisecond
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+func randString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rand.IntN(len(letters))]
+	}
+	return string(b)
+}
+
+// bad
+func newSession() Session {
+	return Session{ID: randString(32), Expires: time.Now().Add(time.Hour)}
+}
+
+// bad
+func generateResetToken() string {
+	buf := make([]byte, 16)
+	mrand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// bad
+func newKey() {
+	r := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 32)
+	r.Read(key)
+	aes.NewCipher(key)
+}
+
+// bad
+func nonceFromTime() int64 {
+	nonce := time.Now().UnixNano()
+	return nonce
+}
+
+// bad
+func renewSession(s *Session) {
+	s.ID = strconv.Itoa(rand.Int())
+}
+
+// good, the time only keeps cache entries apart
+func newCacheEntry() string {
+	cacheKey := fmt.Sprint(time.Now().UnixNano())
+	return cacheKey
+}
+
+// good, request IDs only need to be unique
+func tagRequest() int {
+	requestID := rand.Int()
+	monkey := rand.IntN(10)
+	return requestID + monkey
+}
+
+// good, seeding doesn't matter if nothing sensitive uses the generator
+func shuffle(items []string) {
+	r := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+}
+
+type TokenCache struct {
+	Hits	int
+	Jitter	int
+}
+
+// good, sink words are only part of these names, and the fields aren't tokens
+func cacheStats(c *TokenCache) []int {
+	c.Hits = rand.IntN(10)
+	c.Jitter = rand.IntN(100)
+	footprint := rand.Int()
+	presetIndex := rand.IntN(4)
+	guideline := rand.Int()
+	basalt := rand.Int()
+	return []int{footprint, presetIndex, guideline, basalt}
 }
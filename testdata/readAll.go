@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+)
+
+// bad
+func readAll1(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+}
+
+// bad
+func readAll2(w http.ResponseWriter, r *http.Request) {
+	var v map[string]string
+	json.NewDecoder(r.Body).Decode(&v)
+}
+
+// good
+func readAll3(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	var v map[string]string
+	json.NewDecoder(r.Body).Decode(&v)
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Write(body)
+}
+
+// bad, a gzip bomb
+func readAll4(r io.Reader) ([]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, gz)
+	return buf.Bytes(), err
+}
+
+// bad, decompressed to disk
+func readAll5(f *zip.File, out *os.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+// good
+func readAll6(f *zip.File, out *os.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.CopyN(out, rc, 1<<20)
+	return err
+}
+
+// bad
+func readAll7(conn net.Conn) (string, error) {
+	br := bufio.NewReader(conn)
+	return br.ReadString('\n')
+}
+
+// good
+func readAll8(conn net.Conn) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(conn, 4096))
+}
+
+// good, a file is not untrusted input
+func readAll9(f *os.File) ([]byte, error) {
+	return io.ReadAll(f)
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"embed"
+)
+
+// bad, the embedded key is scanned with -secrets
+//go:embed assets/*
+var assets embed.FS
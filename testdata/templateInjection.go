@@ -0,0 +1,63 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"os/exec"
+)
+
+func runCmd(name string) string {
+	out, err := exec.Command(name).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+func upper(s string) string {
+	return s
+}
+
+func templateHandler(w http.ResponseWriter, r *http.Request) {
+	// bad
+	tmpl, err := template.New("page").Parse(r.FormValue("tmpl"))
+	if err != nil {
+		return
+	}
+
+	// bad
+	name := r.URL.Query().Get("name")
+	path := "templates/" + name
+	tmpl, err = template.ParseFiles(path)
+	if err != nil {
+		return
+	}
+
+	// good
+	tmpl, err = template.New("static").Parse(`{{.}}`)
+	if err != nil {
+		return
+	}
+
+	// bad
+	tmpl.Funcs(template.FuncMap{
+		"run":   runCmd,
+		"upper": upper,
+		"shell": func(s string) error { return exec.Command("sh", "-c", s).Run() },
+	})
+
+	render("page", `{{.}}`)
+	renderPage(r.FormValue("page"))
+
+	tmpl.Execute(w, nil)
+}
+
+// good, only ever given constants
+func render(name, text string) {
+	template.New(name).Parse(text)
+}
+
+// bad, given request data by templateHandler
+func renderPage(text string) {
+	template.New("page").Parse(text)
+}
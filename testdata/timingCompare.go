@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bad
+func checkToken(r *http.Request, expected string) bool {
+	token := r.Header.Get("X-Token")
+	return token == expected
+}
+
+// bad
+func checkMAC(message, messageMAC, key []byte) bool {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	computed := mac.Sum(nil)
+	return bytes.Equal(computed, messageMAC)
+}
+
+// bad
+func checkHash(password string, stored [32]byte) bool {
+	sum := sha256.Sum256([]byte(password))
+	return sum == stored
+}
+
+// bad
+func checkAPIKey(given, apiKey string) bool {
+	return strings.Compare(given, apiKey) == 0
+}
+
+// good
+func checkMACSafely(message, messageMAC, key []byte) bool {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return hmac.Equal(mac.Sum(nil), messageMAC)
+}
+
+// good
+func checkTokenSafely(token, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// good, emptiness isn't secret
+func hasToken(token string) bool {
+	return token != ""
+}
+
+// good, not secret
+func sameName(name, other string) bool {
+	return name == other
+}
+
+// good, sig and hash are only part of these words
+func sameDesign(design, signal, hashmap string, other string) bool {
+	return design == other || signal == other || hashmap == other
+}
+
+// bad
+func checkSig(sig, expectedSig []byte) bool {
+	return bytes.Equal(sig, expectedSig)
+}
+
+// good, neither is secret
+func checkTokenType(tokenType, footprint, other string) bool {
+	return tokenType == "Bearer" || footprint == other
+}
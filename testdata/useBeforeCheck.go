@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// bad
+func useBeforeCheck1(url string) error {
+	resp, err := http.Get(url)
+	defer resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// bad
+func useBeforeCheck2(name string) (string, error) {
+	f, err := os.Open(name)
+	fmt.Println(f.Name())
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return f.Name(), nil
+}
+
+// bad
+func useBeforeCheck3(db *sql.DB) error {
+	rows, err := db.Query("SELECT 1")
+	defer rows.Close()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// good
+func useBeforeCheck4(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// good
+func useBeforeCheck5(name string) string {
+	f, err := os.Open(name)
+	if err == nil {
+		defer f.Close()
+		return f.Name()
+	}
+	return ""
+}
+
+// good, the value is checked instead
+func useBeforeCheck6(name string) {
+	f, err := os.Open(name)
+	if f != nil {
+		defer f.Close()
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// good, log.Fatal doesn't return
+func useBeforeCheck7(url string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+// good, neither does t.Fatal
+func useBeforeCheck8(t *testing.T, name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer f.Close()
+}
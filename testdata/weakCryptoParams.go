@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/dsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+var globalNonce = make([]byte, 12)
+
+var staticSalt = []byte("static")
+
+func weakParams(password []byte) {
+	// bad
+	rsa.GenerateKey(rand.Reader, 1024)
+	// good
+	rsa.GenerateKey(rand.Reader, 4096)
+
+	// bad
+	curve := elliptic.P224()
+
+	// bad
+	var params dsa.Parameters
+	dsa.GenerateParameters(&params, rand.Reader, dsa.L1024N160)
+
+	// bad
+	bcrypt.GenerateFromPassword(password, 4)
+	// good
+	bcrypt.GenerateFromPassword(password, 12)
+
+	// bad
+	pbkdf2.Key(password, []byte("salt"), 1000, 32, sha256.New)
+	// bad
+	scrypt.Key(password, make([]byte, 8), 1024, 1, 1, 32)
+
+	salt := make([]byte, 16)
+	io.ReadFull(rand.Reader, salt)
+	// good
+	pbkdf2.Key(password, salt, 600000, 32, sha256.New)
+
+	if curve == nil {
+		return
+	}
+}
+
+func weakNonces(key, plaintext []byte) {
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+
+	// bad, constant
+	gcm.Seal(nil, []byte("123456789012"), plaintext, nil)
+
+	// bad, never randomized
+	zero := make([]byte, gcm.NonceSize())
+	gcm.Seal(nil, zero, plaintext, nil)
+
+	// bad, package level
+	gcm.Seal(nil, globalNonce, plaintext, nil)
+
+	// good
+	nonce := make([]byte, gcm.NonceSize())
+	io.ReadFull(rand.Reader, nonce)
+	gcm.Seal(nil, nonce, plaintext, nil)
+	// bad, reused
+	gcm.Seal(nil, nonce, plaintext, nil)
+
+	// bad, reused in a loop
+	iv := make([]byte, aes.BlockSize)
+	io.ReadFull(rand.Reader, iv)
+	for i := 0; i < 3; i++ {
+		cipher.NewCBCEncrypter(block, iv)
+	}
+
+	// good
+	for i := 0; i < 3; i++ {
+		io.ReadFull(rand.Reader, iv)
+		cipher.NewCBCEncrypter(block, iv)
+	}
+
+	// bad, zero IV
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize))
+}
+
+func nonceReads(key, plaintext []byte, counter uint64) {
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+
+	nonce := make([]byte, gcm.NonceSize())
+	io.ReadFull(rand.Reader, nonce)
+	gcm.Seal(nil, nonce, plaintext, nil)
+	// bad, reading the nonce doesn't refresh it
+	log.Printf("%x %d", nonce, len(nonce))
+	gcm.Seal(nil, nonce, []byte(hex.EncodeToString(nonce)), nil)
+
+	// good, a counter nonce
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	gcm.Seal(nonce, nonce, plaintext, nil)
+	binary.BigEndian.PutUint64(nonce[4:], counter + 1)
+	gcm.Seal(nonce, nonce, plaintext, nil)
+
+	// good, copied in
+	prefix := make([]byte, gcm.NonceSize())
+	rand.Read(prefix)
+	copy(nonce, prefix)
+	gcm.Seal(nil, nonce, plaintext, nil)
+}
+
+func scryptParallelism(password, salt []byte) {
+	// bad, with minScryptP set to 2
+	scrypt.Key(password, salt, 1 << 15, 8, 1, 32)
+}
+
+func saltVariables(password []byte) {
+	// bad, constant
+	pbkdf2.Key(password, staticSalt, 600000, 32, sha256.New)
+
+	// bad, too short
+	salt := make([]byte, 8)
+	rand.Read(salt)
+	pbkdf2.Key(password, salt, 600000, 32, sha256.New)
+}
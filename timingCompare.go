@@ -0,0 +1,174 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+func init() {
+	register("timingCompare",
+		"this checks for secrets compared in non-constant time",
+		timingCompareCheck,
+		funcDecl)
+}
+
+// secretSources are calls that return digests and MACs
+var secretSources = map[string]bool{
+	"(hash.Hash).Sum":			true,
+	"crypto/md5.Sum":			true,
+	"crypto/sha1.Sum":			true,
+	"crypto/sha256.Sum224":			true,
+	"crypto/sha256.Sum256":			true,
+	"crypto/sha512.Sum384":			true,
+	"crypto/sha512.Sum512":			true,
+	"crypto/sha3.Sum256":			true,
+	"crypto/sha3.Sum512":			true,
+	"golang.org/x/crypto/sha3.Sum256":	true,
+	"golang.org/x/crypto/sha3.Sum512":	true,
+	"golang.org/x/crypto/blake2b.Sum256":	true,
+	"golang.org/x/crypto/blake2b.Sum512":	true,
+}
+
+// unsafeCompares are comparison functions that return at the first difference
+var unsafeCompares = map[string]bool{
+	"bytes.Equal":		true,
+	"bytes.Compare":	true,
+	"strings.Compare":	true,
+	"strings.EqualFold":	true,
+	"reflect.DeepEqual":	true,
+}
+
+// isSecretName checks if an identifier names a MAC, hash, token, key or password
+func isSecretName(name string) bool {
+	// tokenType and passwordPath are about a secret, not secret themselves
+	if hasNameWord(name, nonSecretNames...) {
+		return false;
+	}
+	if getNameUsage(name) != "" {
+		return true;
+	}
+	// whole words only, so design, signal, signup and hashmap don't count
+	return hasNameWord(name, "hash", "digest", "sig", "signature", "mac", "hmac");
+}
+
+// isSecretType checks if a type is one configured to always hold secrets
+func isSecretType(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	for _, name := range config.Secrets.Types {
+		if t.String() == name {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isByteString checks if a type is a string, byte slice or byte array,
+// the things secrets are kept in
+func isByteString(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return u.Kind() == types.String;
+	case *types.Slice:
+		b, ok := u.Elem().Underlying().(*types.Basic);
+		return ok && b.Kind() == types.Byte;
+	case *types.Array:
+		b, ok := u.Elem().Underlying().(*types.Basic);
+		return ok && b.Kind() == types.Byte;
+	}
+	return false;
+}
+
+// getSecret returns a description of the secret an operand holds
+// or an empty string if it doesn't look like one
+func getSecret(f *File, x ast.Expr, tainted map[types.Object]bool) string {
+	t := f.pkg.info.TypeOf(x);
+	if t == nil {
+		return "";
+	}
+	if isSecretType(t) {
+		return t.String();
+	}
+	if !isByteString(t) {
+		return "";
+	}
+	// x[:] and []byte(x) hold the same secret as x
+	switch e := x.(type) {
+	case *ast.ParenExpr:
+		return getSecret(f, e.X, tainted);
+	case *ast.SliceExpr:
+		return getSecret(f, e.X, tainted);
+	case *ast.CallExpr:
+		if len(e.Args) == 1 && f.pkg.info.Types[e.Fun].IsType() {
+			return getSecret(f, e.Args[0], tainted);
+		}
+		if name := getFuncName(e); isSecretName(name) {
+			return name;
+		}
+	}
+	if name := getLhsName(x); name != "" && isSecretName(name) {
+		return name;
+	}
+	if usesTainted(f, x, tainted, secretSources) {
+		return "digest " + f.ASTString(x);
+	}
+	return "";
+}
+
+// isConstOrNil checks for comparisons against fixed values like token == ""
+func isConstOrNil(f *File, x ast.Expr) bool {
+	if tv, ok := f.pkg.info.Types[x]; ok && tv.Value != nil {
+		return true;
+	}
+	return isNil(f, x);
+}
+
+func timingCompareCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
+	}
+	// anything assigned from a digest is secret
+	tainted := make(map[types.Object]bool);
+	spreadTaint(f, fun.Body, tainted, secretSources);
+
+	formatString := "secret %s compared in non-constant time, use subtle.ConstantTimeCompare or hmac.Equal: %s";
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.BinaryExpr:
+			if x.Op != token.EQL && x.Op != token.NEQ {
+				return true;
+			}
+			if isConstOrNil(f, x.X) || isConstOrNil(f, x.Y) {
+				return true;
+			}
+			secret := getSecret(f, x.X, tainted);
+			if secret == "" {
+				secret = getSecret(f, x.Y, tainted);
+			}
+			if secret != "" {
+				f.Reportf(x.Pos(), formatString, secret, f.ASTString(x));
+			}
+		case *ast.CallExpr:
+			if !unsafeCompares[getCalleeName(f, x)] || len(x.Args) != 2 {
+				return true;
+			}
+			if isConstOrNil(f, x.Args[0]) || isConstOrNil(f, x.Args[1]) {
+				return true;
+			}
+			for _, arg := range x.Args {
+				if secret := getSecret(f, arg, tainted); secret != "" {
+					f.Reportf(x.Pos(), formatString, secret, f.ASTString(x));
+					break;
+				}
+			}
+		}
+		return true;
+	});
+	return;
+}
@@ -4,8 +4,11 @@
 package main
 
 import (
+	"crypto/tls"
+	"fmt"
 	"go/ast"
-	"strconv"
+	"go/token"
+	"go/types"
 )
 
 // TLS is an acronym, therefore it should be in caps. It doesn't matter if it is exportable.
@@ -13,116 +16,205 @@ func init() {
 	register("TLSConfig",
 		"this is a check for insecure TLS configuration",
 		iTLSConfigCheck,
-		compositeLit)
+		compositeLit, assignStmt)
 }
 
-const (
-        VersionSSL30 = 0x0300
-        VersionTLS10 = 0x0301
-        VersionTLS11 = 0x0302
-        VersionTLS12 = 0x0303
-)
+// secureCurves are the key exchange curves crypto/tls offers that are considered safe
+var secureCurves = map[int64]bool{
+	int64(tls.X25519):	true,
+	int64(tls.CurveP256):	true,
+	int64(tls.CurveP384):	true,
+	int64(tls.CurveP521):	true,
+	0x11ec:			true, // X25519MLKEM768, newer than some toolchains
+}
 
-var secureCiphers = []string {
-        "TLS_RSA_WITH_AES_128_CBC_SHA",
-        "TLS_RSA_WITH_AES_256_CBC_SHA",
-        "TLS_RSA_WITH_AES_128_CBC_SHA256",
-        "TLS_RSA_WITH_AES_128_GCM_SHA256",
-        "TLS_RSA_WITH_AES_256_GCM_SHA384",
-        "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
-        "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
-        "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
-        "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
-        "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
-        "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
-        "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
-        "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
-        "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
-        "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
-        "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
-        "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305",
-	"0x002f", "0x0035", "0x003c", "0x009c", "0x009d", "0xc009", "0xc00a", "0xc013", "0xc014", "0xc023",
-	"0xc027", "0xc02f", "0xc02b", "0xc030", "0xc02c", "0xcca8", "0xcca9",
+// tlsVersions are the names of TLS versions the minVersion setting takes
+var tlsVersions = map[string]uint16{
+	"1.0":	tls.VersionTLS10,
+	"1.1":	tls.VersionTLS11,
+	"1.2":	tls.VersionTLS12,
+	"1.3":	tls.VersionTLS13,
 }
 
-func sliceContains(s string, slice []string) bool {
-	for _, str := range secureCiphers {
-		if s == str {
-			return true;
-		}
+// minTLSVersion is the oldest TLS version allowed, set from the config file.
+// crypto/tls defaults to 1.2 for clients and servers
+var minTLSVersion uint16 = tls.VersionTLS12
+
+// setMinTLSVersion looks up the configured minimum TLS version
+func setMinTLSVersion(conf TLSPolicyConfig) error {
+	version, ok := tlsVersions[conf.MinVersion];
+	if !ok {
+		return fmt.Errorf("unknown TLS version %q", conf.MinVersion);
 	}
-	return false;
+	minTLSVersion = version;
+	return nil;
 }
 
-// checkTLSConfVal checks a key value expression from within a tls.Config composite element
-// the idea is to check the values of these things for bad ones
-func checkTLSConfVal(f *File, keyValueExpr *ast.KeyValueExpr) {
-	// we are converting this down to identifiers where it is a 'basic' element type just in case
-	if ident, ok := keyValueExpr.Key.(*ast.Ident); ok {
-		switch ident.Name {
+// isTLSConfig checks if a type is crypto/tls.Config or a pointer to one
+func isTLSConfig(t types.Type) bool {
+	if t == nil {
+		return false;
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	named, ok := types.Unalias(t).(*types.Named);
+	if !ok {
+		return false;
+	}
+	obj := named.Obj();
+	return obj.Pkg() != nil && obj.Pkg().Path() == "crypto/tls" && obj.Name() == "Config";
+}
 
-		case "InsecureSkipVerify":
-			if val, ok := keyValueExpr.Value.(*ast.Ident); ok {
-				if (val.Name == "true") {
-					f.Reportf(keyValueExpr.Pos(), "InsecureSkipVerify is enabled, %s", f.ASTString(keyValueExpr));
-				}
-			} else {
-				// value is not a basic identifier, so simple boolean values can't be checked
-				f.Reportf(keyValueExpr.Pos(), "Audit use of InsecureSkipVerify, %s", f.ASTString(keyValueExpr));
+// alwaysReturnsNil checks if a callback, either a function literal or
+// a function declared in the package, returns nil on every path
+func alwaysReturnsNil(f *File, x ast.Expr) bool {
+	var body *ast.BlockStmt
+	switch v := x.(type) {
+	case *ast.FuncLit:
+		body = v.Body;
+	case *ast.Ident:
+		if fn, ok := f.pkg.info.Uses[v].(*types.Func); ok {
+			if decl := getFuncDecl(f, fn); decl != nil {
+				body = decl.Body;
 			}
-		case "PreferServerCipherSuites":
-			if val, ok := keyValueExpr.Value.(*ast.Ident); ok {
-				if val.Name == "false" {
-					f.Reportf(keyValueExpr.Pos(), "PreferServerCipherSuites set to false, %s", f.ASTString(keyValueExpr));
-				}
-			} else {
-				// can't be shown to be true; some sort of weird expression instead of simple true or false
-				f.Reportf(keyValueExpr.Pos(), "Audit use of PreferServerCipherSuites, %s", f.ASTString(keyValueExpr));
+		}
+	}
+	if body == nil {
+		return false;
+	}
+	returns := 0;
+	onlyNil := true;
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.FuncLit:
+			// returns inside nested closures don't count
+			return false;
+		case *ast.ReturnStmt:
+			returns++;
+			if len(stmt.Results) != 1 || !isNil(f, stmt.Results[0]) {
+				onlyNil = false;
 			}
-		case "MinVersion":
-			if val, ok := keyValueExpr.Value.(*ast.BasicLit); ok {
-				i, err := strconv.Atoi(val.Value);
-				if err == nil {
-					if ((int16)(i) < VersionTLS10) {
-						f.Reportf(keyValueExpr.Pos(), "TLS minimum version is outdated, %s", f.ASTString(keyValueExpr));
-					}
-				}
+		}
+		return onlyNil;
+	});
+	return returns > 0 && onlyNil;
+}
+
+// checkTLSField checks the value given to a field of a tls.Config
+// whether it is set in a composite literal or assigned afterwards.
+// desc is the source of the key value pair or assignment for reporting.
+func checkTLSField(f *File, pos token.Pos, name string, value ast.Expr, desc string) {
+	switch name {
+	case "InsecureSkipVerify":
+		if val, ok := getConstBool(f, value); ok {
+			if val {
+				f.Reportf(pos, "InsecureSkipVerify is enabled, %s", desc);
 			}
-		case "MaxVersion":
-			if val, ok := keyValueExpr.Value.(*ast.BasicLit); ok {
-				i, err := strconv.Atoi(val.Value);
-				if err == nil {
-					if ((int16)(i) < VersionTLS11) {
-						// todo: maybe reword this issue?
-						f.Reportf(keyValueExpr.Pos(), "TLS maximum version is weak, %s", f.ASTString(keyValueExpr));
-					}
-				}
+		} else {
+			// value is not constant, so it can't be checked
+			f.Reportf(pos, "Audit use of InsecureSkipVerify, %s", desc);
+		}
+	case "PreferServerCipherSuites":
+		if val, ok := getConstBool(f, value); ok {
+			if !val {
+				f.Reportf(pos, "PreferServerCipherSuites set to false, %s", desc);
+			}
+		} else {
+			// can't be shown to be true; some sort of weird expression instead of simple true or false
+			f.Reportf(pos, "Audit use of PreferServerCipherSuites, %s", desc);
+		}
+	case "MinVersion":
+		// zero means the default, which is TLS 1.2
+		if val, ok := getConstInt(f, value); ok && val != 0 && val < int64(minTLSVersion) {
+			f.Reportf(pos, "TLS minimum version is outdated, %s", desc);
+		}
+	case "MaxVersion":
+		// zero means the highest version supported
+		if val, ok := getConstInt(f, value); ok && val != 0 && val < int64(minTLSVersion) {
+			// todo: maybe reword this issue?
+			f.Reportf(pos, "TLS maximum version is weak, %s", desc);
+		}
+	case "CipherSuites":
+		if val, ok := value.(*ast.CompositeLit); ok {
+			for _, elt := range val.Elts {
+				checkCipherSuite(f, elt);
+			}
+		}
+	case "Renegotiation":
+		if val, ok := getConstInt(f, value); ok {
+			switch tls.RenegotiationSupport(val) {
+			case tls.RenegotiateFreelyAsClient:
+				f.Reportf(pos, "TLS renegotiation is allowed repeatedly, %s", desc);
+			case tls.RenegotiateOnceAsClient:
+				f.Reportf(pos, "Audit use of TLS renegotiation, %s", desc);
 			}
-		case "CipherSuites":
-			if val, ok := keyValueExpr.Value.(*ast.CompositeLit); ok {
-				for _, elt := range val.Elts {
-					if cipherLit, ok := elt.(*ast.BasicLit); ok {
-						if !sliceContains(cipherLit.Value, secureCiphers) {
-							f.Reportf(cipherLit.Pos(), "Weak cipher, %s, is in use", f.ASTString(cipherLit));
-						}
-					}
-				}	
+		} else {
+			f.Reportf(pos, "Audit use of TLS renegotiation, %s", desc);
+		}
+	case "ClientAuth":
+		if val, ok := getConstInt(f, value); ok {
+			switch tls.ClientAuthType(val) {
+			case tls.RequestClientCert, tls.RequireAnyClientCert:
+				f.Reportf(pos, "client certificates are not verified, %s", desc);
+			}
+		}
+	case "KeyLogWriter":
+		if !isNil(f, value) {
+			f.Reportf(pos, "TLS secrets are written to a key log, %s", desc);
+		}
+	case "CurvePreferences":
+		if val, ok := value.(*ast.CompositeLit); ok {
+			for _, elt := range val.Elts {
+				if curve, ok := getConstInt(f, elt); ok && !secureCurves[curve] {
+					f.Reportf(elt.Pos(), "Weak or unknown curve, %s, is in use", f.ASTString(elt));
+				}
 			}
 		}
+	case "VerifyPeerCertificate", "VerifyConnection":
+		if alwaysReturnsNil(f, value) {
+			f.Reportf(pos, "%s callback always returns nil and accepts any certificate", name);
+		}
+	}
+}
+
+// checkTLSAssign checks assignments to fields of a tls.Config
+// i.e. config.InsecureSkipVerify = true
+func checkTLSAssign(f *File, stmt *ast.AssignStmt) {
+	if len(stmt.Lhs) != len(stmt.Rhs) {
+		return;
+	}
+	for i, lhs := range stmt.Lhs {
+		sel, ok := lhs.(*ast.SelectorExpr);
+		if !ok {
+			continue;
+		}
+		selection, ok := f.pkg.info.Selections[sel];
+		if !ok || selection.Kind() != types.FieldVal || !isTLSConfig(selection.Recv()) {
+			continue;
+		}
+		desc := fmt.Sprintf("%s = %s", f.ASTString(lhs), f.ASTString(stmt.Rhs[i]));
+		checkTLSField(f, stmt.Pos(), sel.Sel.Name, stmt.Rhs[i], desc);
 	}
 }
 
 // iTLSConfigCheck checks TLS configuration structs.
 // i stands for insecure. It also prevents this from being exportable.
 func iTLSConfigCheck(f *File, node ast.Node) {
-	if compLit, ok := node.(*ast.CompositeLit); ok && compLit.Type != nil {
+	switch t := node.(type) {
+	case *ast.CompositeLit:
+		if !isTLSConfig(f.pkg.info.TypeOf(t)) {
+			return;
+		}
 		// elt stands for element, as in, composite element
-		for _, elt := range compLit.Elts {
+		for _, elt := range t.Elts {
 			if keyValueExpr, ok := elt.(*ast.KeyValueExpr); ok {
-				// more succinct to call another function here
-				checkTLSConfVal(f, keyValueExpr);
+				if key, ok := keyValueExpr.Key.(*ast.Ident); ok {
+					checkTLSField(f, keyValueExpr.Pos(), key.Name, keyValueExpr.Value, f.ASTString(keyValueExpr));
+				}
 			}
 		}
+	case *ast.AssignStmt:
+		checkTLSAssign(f, t);
 	}
 }
-
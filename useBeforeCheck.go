@@ -0,0 +1,312 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func init() {
+	register("useBeforeCheck",
+		"this tests for results used before the error returned with them is checked",
+		useBeforeCheck,
+		funcDecl)
+}
+
+// isNilable checks if a result can be nil when an error is returned with it
+// and using it would panic, pointers and interfaces
+func isNilable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return true;
+	}
+	return false;
+}
+
+// derefs checks if an instruction dereferences a value,
+// i.e. resp.Body, *p, or a method call on it
+func derefs(instr ssa.Instruction, v ssa.Value) bool {
+	switch i := instr.(type) {
+	case *ssa.FieldAddr:
+		return i.X == v;
+	case *ssa.Field:
+		return i.X == v;
+	case *ssa.IndexAddr:
+		return i.X == v;
+	case *ssa.UnOp:
+		return i.Op == token.MUL && i.X == v;
+	case ssa.CallInstruction:
+		common := i.Common();
+		if common.IsInvoke() {
+			return common.Value == v;
+		}
+		// few methods expect a nil receiver, (*sql.Rows).Close doesn't
+		if callee := common.StaticCallee(); callee != nil && callee.Signature.Recv() != nil {
+			return len(common.Args) > 0 && common.Args[0] == v;
+		}
+	}
+	return false;
+}
+
+// getErrorGuards returns the blocks that are only reached once the error
+// returned with a value, or the value itself, has been checked against nil
+func getErrorGuards(res closerResource) []*ssa.BasicBlock {
+	var guards []*ssa.BasicBlock
+	for _, x := range []ssa.Value{res.err, res.value} {
+		refs := x.Referrers();
+		if refs == nil {
+			continue;
+		}
+		for _, ref := range *refs {
+			cond, ok := ref.(*ssa.BinOp);
+			if !ok || cond.Referrers() == nil {
+				continue;
+			}
+			for _, use := range *cond.Referrers() {
+				ifInstr, ok := use.(*ssa.If);
+				if !ok {
+					continue;
+				}
+				if succ := getGuardedSucc(res, ifInstr); succ >= 0 {
+					guards = append(guards, ifInstr.Block().Succs[succ]);
+				}
+			}
+		}
+	}
+	return guards;
+}
+
+// noReturnFuncs never return to their caller, so a branch calling one
+// doesn't reach anything after it, i.e. if err != nil { log.Fatal(err) }
+var noReturnFuncs = map[string]bool{
+	"log.Fatal":				true,
+	"log.Fatalf":				true,
+	"log.Fatalln":				true,
+	"(*log.Logger).Fatal":			true,
+	"(*log.Logger).Fatalf":			true,
+	"(*log.Logger).Fatalln":		true,
+	"os.Exit":				true,
+	"runtime.Goexit":			true,
+	"(*testing.common).Fatal":		true,
+	"(*testing.common).Fatalf":		true,
+	"(*testing.common).FailNow":		true,
+	"(*testing.common).Skip":		true,
+	"(*testing.common).Skipf":		true,
+	"(*testing.common).SkipNow":		true,
+	"(testing.TB).Fatal":			true,
+	"(testing.TB).Fatalf":			true,
+	"(testing.TB).FailNow":			true,
+	"(testing.TB).Skip":			true,
+	"(testing.TB).Skipf":			true,
+	"(testing.TB).SkipNow":			true,
+}
+
+// callsNoReturn checks if a block calls a function that never returns
+func callsNoReturn(b *ssa.BasicBlock) bool {
+	for _, instr := range b.Instrs {
+		call, ok := instr.(*ssa.Call);
+		if !ok {
+			continue;
+		}
+		common := call.Common();
+		if common.IsInvoke() {
+			if noReturnFuncs[common.Method.FullName()] {
+				return true;
+			}
+		} else if callee := common.StaticCallee(); callee != nil && noReturnFuncs[callee.String()] {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isGuarded checks if an instruction only runs after one of the guards
+func isGuarded(instr ssa.Instruction, guards []*ssa.BasicBlock) bool {
+	for _, g := range guards {
+		// the guarded side must only be entered from the check,
+		// branches that never return, like log.Fatal, don't count
+		preds := 0;
+		for _, p := range g.Preds {
+			if !callsNoReturn(p) {
+				preds++;
+			}
+		}
+		if preds == 1 && g.Dominates(instr.Block()) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// getResults returns the nilable results of a call paired with the error returned with them
+func getResults(call *ssa.Call) []closerResource {
+	tuple, ok := call.Type().(*types.Tuple);
+	if !ok {
+		return nil;
+	}
+	var values []*ssa.Extract
+	var errV ssa.Value
+	for _, ref := range *call.Referrers() {
+		ext, ok := ref.(*ssa.Extract);
+		if !ok {
+			continue;
+		}
+		t := tuple.At(ext.Index).Type();
+		switch {
+		case isErrorType(t):
+			errV = ext;
+		case isNilable(t):
+			values = append(values, ext);
+		}
+	}
+	if errV == nil {
+		return nil;
+	}
+	var results []closerResource
+	for _, v := range values {
+		results = append(results, closerResource{call: call, value: v, err: errV});
+	}
+	return results;
+}
+
+// checkUseBeforeCheckSSA looks for results that are dereferenced somewhere
+// the error returned with them hasn't been checked yet
+func checkUseBeforeCheckSSA(f *File, fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call);
+			if !ok {
+				continue;
+			}
+			for _, res := range getResults(call) {
+				guards := getErrorGuards(res);
+				// errors that are never checked are left to the error and deadError tests
+				if len(guards) == 0 {
+					continue;
+				}
+				for _, ref := range *res.value.Referrers() {
+					if derefs(ref, res.value) && !isGuarded(ref, guards) {
+						pos := ref.Pos();
+						if pos == token.NoPos {
+							pos = call.Pos();
+						}
+						f.Reportf(pos, "%s used before checking the error returned with it, it may be nil: %s", res.value.Type(), getCallString(f, call.Pos()));
+						break;
+					}
+				}
+			}
+		}
+	}
+	for _, anon := range fn.AnonFuncs {
+		checkUseBeforeCheckSSA(f, anon);
+	}
+}
+
+// usesObject checks if a node refers to a variable
+func usesObject(f *File, n ast.Node, obj types.Object) bool {
+	found := false;
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && f.pkg.info.Uses[id] == obj {
+			found = true;
+		}
+		return !found;
+	});
+	return found;
+}
+
+// getDeref returns where a node dereferences a variable, i.e. resp.Body or *p
+func getDeref(f *File, n ast.Node, obj types.Object) ast.Node {
+	var deref ast.Node
+	ast.Inspect(n, func(n ast.Node) bool {
+		var x ast.Expr
+		switch e := n.(type) {
+		case *ast.SelectorExpr:
+			x = e.X;
+		case *ast.StarExpr:
+			x = e.X;
+		}
+		if id, ok := x.(*ast.Ident); ok && f.pkg.info.Uses[id] == obj {
+			deref = n;
+		}
+		return deref == nil;
+	});
+	return deref;
+}
+
+// checkUseBeforeCheckAST is used when the package can't be built into SSA.
+// it follows the statements after v, err := f() in the same block
+// until err, or v in an if condition, is used, looking for v being dereferenced first
+func checkUseBeforeCheckAST(f *File, body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		block, ok := n.(*ast.BlockStmt);
+		if !ok {
+			return true;
+		}
+		for i, stmt := range block.List {
+			assign, ok := stmt.(*ast.AssignStmt);
+			if !ok || len(assign.Rhs) != 1 || len(assign.Lhs) < 2 {
+				continue;
+			}
+			call, ok := assign.Rhs[0].(*ast.CallExpr);
+			if !ok {
+				continue;
+			}
+			var errObj types.Object
+			var values []types.Object
+			for _, lhs := range assign.Lhs {
+				obj := getObject(f, lhs);
+				if obj == nil || obj.Name() == "_" {
+					continue;
+				}
+				if isErrorType(obj.Type()) {
+					errObj = obj;
+				} else if isNilable(obj.Type()) {
+					values = append(values, obj);
+				}
+			}
+			if errObj == nil {
+				continue;
+			}
+			for _, v := range values {
+				for _, next := range block.List[i + 1:] {
+					if usesObject(f, next, errObj) {
+						break;
+					}
+					// if v != nil
+					if ifStmt, ok := next.(*ast.IfStmt); ok && usesObject(f, ifStmt.Cond, v) {
+						break;
+					}
+					if deref := getDeref(f, next, v); deref != nil {
+						f.Reportf(deref.Pos(), "%s used before checking the error returned with it, it may be nil: %s", v.Type(), f.ASTString(call));
+						break;
+					}
+				}
+			}
+		}
+		return true;
+	});
+}
+
+// useBeforeCheck looks for results like an *http.Response, *os.File or *sql.Rows
+// that are used before the error returned with them is checked, i.e.
+//
+//	resp, err := http.Get(url)
+//	defer resp.Body.Close()
+//	if err != nil {
+//
+// which panics when there is an error
+func useBeforeCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
+	}
+	if fn := getSSAFunc(f, fun); fn != nil {
+		checkUseBeforeCheckSSA(f, fn);
+		return;
+	}
+	checkUseBeforeCheckAST(f, fun.Body);
+}
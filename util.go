@@ -3,6 +3,8 @@ package main
 import(
 	"math"
 	"regexp"
+	"strings"
+	"unicode"
 )
 
 // computes Shannon Entropy, H. Note this is
@@ -73,3 +75,59 @@ func isAlphanum(s string) (bool, error) {
 	}
 	return false, nil;
 }	
+
+// splitName splits an identifier or key into lower case words at underscores,
+// dashes, dots and camel case boundaries, i.e. HMACKey is hmac and key
+// and api_token_2 is api, token and 2
+func splitName(name string) []string {
+	var words []string
+	runes := []rune(name);
+	start := 0;
+	for i := 0; i <= len(runes); i++ {
+		split, skip := i == len(runes), false;
+		if !split {
+			r := runes[i];
+			switch {
+			case r == '_' || r == '-' || r == '.' || unicode.IsSpace(r):
+				split, skip = true, true;
+			case i > start && unicode.IsUpper(r):
+				prev := runes[i - 1];
+				// fooBar, or the end of an acronym in HMACKey
+				split = unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+					(unicode.IsUpper(prev) && i + 1 < len(runes) && unicode.IsLower(runes[i + 1]));
+			case i > start && unicode.IsDigit(r) != unicode.IsDigit(runes[i - 1]):
+				split = true;
+			}
+		}
+		if !split {
+			continue;
+		}
+		if i > start {
+			words = append(words, strings.ToLower(string(runes[start:i])));
+		}
+		start = i;
+		if skip {
+			start = i + 1;
+		}
+	}
+	return words;
+}
+
+// hasNameWord checks if an identifier has one of words as a whole word, as split by splitName.
+// a word can also be spelt over consecutive parts, so apikey matches apiKey and API_KEY,
+// and plurals count, so token matches refreshTokens
+func hasNameWord(name string, words ...string) bool {
+	parts := splitName(name);
+	for i := range parts {
+		joined := "";
+		for _, part := range parts[i:] {
+			joined += part;
+			for _, word := range words {
+				if joined == word || joined == word + "s" {
+					return true;
+				}
+			}
+		}
+	}
+	return false;
+}
@@ -0,0 +1,360 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+func init() {
+	register("weakCryptoParams",
+		"this test checks for weak parameters given to cryptographic functions",
+		weakCryptoParamsCheck,
+		callExpr, funcDecl)
+}
+
+// kdfArgs gives the argument positions of key derivation functions
+// a negative position means the function doesn't take that argument
+type kdfArgs struct {
+	salt	int
+	iter	int
+	n	int
+	r	int
+	p	int
+}
+
+var kdfs = map[string]kdfArgs{
+	"golang.org/x/crypto/pbkdf2.Key":	{salt: 1, iter: 2, n: -1, r: -1, p: -1},
+	"crypto/pbkdf2.Key":			{salt: 2, iter: 3, n: -1, r: -1, p: -1},
+	"golang.org/x/crypto/scrypt.Key":	{salt: 1, iter: -1, n: 2, r: 3, p: 4},
+}
+
+// nonceArgs maps functions taking a nonce or IV to the argument position
+var nonceArgs = map[string]int{
+	"(crypto/cipher.AEAD).Seal":		1,
+	"crypto/cipher.NewCBCEncrypter":	1,
+	"crypto/cipher.NewCFBEncrypter":	1,
+	"crypto/cipher.NewCTR":			1,
+	"crypto/cipher.NewOFB":			1,
+}
+
+// nonceFillers give new contents to the slice passed at the argument position.
+// binary.BigEndian.PutUint64 and the like are matched by isNonceFill
+var nonceFillers = map[string]int{
+	"io.ReadFull":				1,
+	"io.ReadAtLeast":			1,
+	"(io.Reader).Read":			0,
+	"crypto/rand.Read":			0,
+	"math/rand.Read":			0,
+	"(*math/rand.Rand).Read":		0,
+	"(*math/rand/v2.ChaCha8).Read":		0,
+}
+
+// getByteLen works out the length of a constant byte slice or string expression
+// isConst is true if the contents are fixed too, not just the length
+func getByteLen(f *File, x ast.Expr) (length int64, isConst bool, ok bool) {
+	if s, ok := getConstString(f, x); ok {
+		return int64(len(s)), true, true;
+	}
+	switch e := x.(type) {
+	case *ast.CallExpr:
+		// []byte("salt") is a conversion, not a call
+		if len(e.Args) == 1 && f.pkg.info.Types[e.Fun].IsType() {
+			if s, ok := getConstString(f, e.Args[0]); ok {
+				return int64(len(s)), true, true;
+			}
+		}
+		if getFuncName(e) == "make" && len(e.Args) > 1 {
+			if n, ok := getConstInt(f, e.Args[1]); ok {
+				return n, false, true;
+			}
+		}
+	case *ast.CompositeLit:
+		for _, elt := range e.Elts {
+			if tv, ok := f.pkg.info.Types[elt]; !ok || tv.Value == nil {
+				return int64(len(e.Elts)), false, true;
+			}
+		}
+		return int64(len(e.Elts)), true, true;
+	}
+	return 0, false, false;
+}
+
+// isFilled checks if a variable is given new contents anywhere in the package,
+// i.e. by rand.Read(salt) or io.ReadFull(rand.Reader, salt)
+func isFilled(f *File, obj types.Object) bool {
+	filled := false;
+	for _, file := range f.pkg.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if i, ok := isNonceFill(f, call); ok && getBufferVar(f, call.Args[i]) == obj {
+					filled = true;
+				}
+			}
+			return !filled;
+		});
+	}
+	return filled;
+}
+
+// getSaltLen works out the length of a salt like getByteLen,
+// following a variable to the single value it is assigned, i.e.
+//
+//	salt := make([]byte, 8)
+//	rand.Read(salt)
+func getSaltLen(f *File, x ast.Expr) (length int64, isConst bool, ok bool) {
+	for depth := 0; depth < maxResolveDepth; depth++ {
+		id, isIdent := unparen(x).(*ast.Ident);
+		if !isIdent {
+			break;
+		}
+		obj, isVar := getObject(f, id).(*types.Var);
+		if !isVar {
+			break;
+		}
+		value := getAssignedValue(f, obj);
+		if value == nil {
+			return 0, false, false;
+		}
+		if isFilled(f, obj) {
+			length, _, ok := getSaltLen(f, value);
+			return length, false, ok;
+		}
+		x = value;
+	}
+	return getByteLen(f, x);
+}
+
+// checkKDF checks salts and work factors given to key derivation functions
+func checkKDF(f *File, call *ast.CallExpr, args kdfArgs) {
+	name := getCalleeName(f, call);
+	if args.salt < len(call.Args) {
+		salt := call.Args[args.salt];
+		if length, isConst, ok := getSaltLen(f, salt); ok {
+			if isConst {
+				f.Reportf(call.Pos(), "constant salt used to derive a key: %s", f.ASTString(call));
+			} else if length < int64(config.Crypto.MinSaltLength) {
+				f.Reportf(call.Pos(), "salt shorter than %d bytes used to derive a key: %s", config.Crypto.MinSaltLength, f.ASTString(call));
+			}
+		}
+	}
+	if args.iter >= 0 && args.iter < len(call.Args) {
+		if iter, ok := getConstInt(f, call.Args[args.iter]); ok && iter < int64(config.Crypto.MinPBKDF2Iterations) {
+			f.Reportf(call.Pos(), "low iteration count, %d, for %s: %s", iter, name, f.ASTString(call));
+		}
+	}
+	if args.n >= 0 && args.n < len(call.Args) {
+		if n, ok := getConstInt(f, call.Args[args.n]); ok && n < int64(config.Crypto.MinScryptN) {
+			f.Reportf(call.Pos(), "weak scrypt cost parameter N, %d: %s", n, f.ASTString(call));
+		}
+	}
+	if args.r >= 0 && args.r < len(call.Args) {
+		if r, ok := getConstInt(f, call.Args[args.r]); ok && r < int64(config.Crypto.MinScryptR) {
+			f.Reportf(call.Pos(), "weak scrypt block size r, %d: %s", r, f.ASTString(call));
+		}
+	}
+	if args.p >= 0 && args.p < len(call.Args) {
+		if p, ok := getConstInt(f, call.Args[args.p]); ok && p < int64(config.Crypto.MinScryptP) {
+			f.Reportf(call.Pos(), "weak scrypt parallelism p, %d: %s", p, f.ASTString(call));
+		}
+	}
+}
+
+// checkCryptoCall checks calls that take weak parameters or are weak themselves
+func checkCryptoCall(f *File, call *ast.CallExpr) {
+	name := getCalleeName(f, call);
+	if name == "" {
+		return;
+	}
+	switch name {
+	case "crypto/rsa.GenerateKey", "crypto/rsa.GenerateMultiPrimeKey":
+		// bits are always the last argument
+		if len(call.Args) == 0 {
+			return;
+		}
+		if bits, ok := getConstInt(f, call.Args[len(call.Args) - 1]); ok && bits < int64(config.Crypto.MinRSABits) {
+			f.Reportf(call.Pos(), "weak RSA key size, %d bits: %s", bits, f.ASTString(call));
+		}
+		return;
+	case "crypto/elliptic.P224":
+		f.Reportf(call.Pos(), "weak elliptic curve P-224 in use: %s", f.ASTString(call));
+		return;
+	case "golang.org/x/crypto/bcrypt.GenerateFromPassword":
+		if len(call.Args) < 2 {
+			return;
+		}
+		if cost, ok := getConstInt(f, call.Args[1]); ok && cost < int64(config.Crypto.MinBcryptCost) {
+			f.Reportf(call.Pos(), "low bcrypt cost, %d: %s", cost, f.ASTString(call));
+		}
+		return;
+	}
+	if args, ok := kdfs[name]; ok {
+		checkKDF(f, call, args);
+		return;
+	}
+	if fn := getCalledFunc(f, call); fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == "crypto/dsa" {
+		f.Reportf(call.Pos(), "DSA is deprecated and weak: %s", f.ASTString(call));
+	}
+}
+
+// nonceEvent is a place in a function where a nonce variable is either
+// used to encrypt or given new contents
+type nonceEvent struct {
+	pos	token.Pos
+	obj	types.Object
+	use	*ast.CallExpr // nil if this is a fill
+}
+
+// isNonceFill checks if a call gives new contents to a nonce and returns the
+// position of the argument it fills, i.e. io.ReadFull(rand.Reader, nonce),
+// binary.BigEndian.PutUint64(nonce, counter) or copy(nonce, counter).
+// anything else given the nonce, i.e. hex.EncodeToString(nonce), only reads it
+func isNonceFill(f *File, call *ast.CallExpr) (int, bool) {
+	if len(call.Args) == 0 {
+		return 0, false;
+	}
+	if id, ok := call.Fun.(*ast.Ident); ok && f.pkg.info.Uses[id] == types.Universe.Lookup("copy") {
+		return 0, true;
+	}
+	name := getCalleeName(f, call);
+	if i, ok := nonceFillers[name]; ok && i < len(call.Args) {
+		return i, true;
+	}
+	// (encoding/binary.bigEndian).PutUint64 and the little endian and append versions
+	fn := getCalledFunc(f, call);
+	if fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == "encoding/binary" && strings.HasPrefix(fn.Name(), "PutUint") {
+		return 0, true;
+	}
+	return 0, false;
+}
+
+// isFreshValue checks if the value assigned to a nonce gives it new contents.
+// make(), new() and constants leave it all zeros or fixed.
+func isFreshValue(f *File, x ast.Expr) bool {
+	if _, _, ok := getByteLen(f, x); ok {
+		return false;
+	}
+	if call, ok := x.(*ast.CallExpr); ok {
+		if name := getFuncName(call); name == "make" || name == "new" {
+			return false;
+		}
+	}
+	return true;
+}
+
+// checkNonces looks for constant, zero and reused nonces and IVs in a function
+func checkNonces(f *File, fun *ast.FuncDecl) {
+	if fun.Body == nil {
+		return;
+	}
+	var events []nonceEvent
+	var loops []ast.Node
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops = append(loops, node);
+		case *ast.AssignStmt:
+			for i, lhs := range node.Lhs {
+				obj := getObject(f, lhs);
+				if obj == nil {
+					continue;
+				}
+				if len(node.Rhs) == len(node.Lhs) && !isFreshValue(f, node.Rhs[i]) {
+					continue;
+				}
+				events = append(events, nonceEvent{pos: node.Pos(), obj: obj});
+			}
+		case *ast.CallExpr:
+			if i, ok := isNonceFill(f, node); ok {
+				if obj := getBufferVar(f, node.Args[i]); obj != nil {
+					events = append(events, nonceEvent{pos: node.Pos(), obj: obj});
+				}
+				return true;
+			}
+			i, ok := nonceArgs[getCalleeName(f, node)];
+			if !ok || i >= len(node.Args) {
+				return true;
+			}
+			// a literal or a freshly made slice is constant or all zeros
+			if _, _, ok := getByteLen(f, node.Args[i]); ok {
+				f.Reportf(node.Pos(), "constant or zero nonce/IV: %s", f.ASTString(node));
+			} else if obj := getBufferVar(f, node.Args[i]); obj != nil {
+				events = append(events, nonceEvent{pos: node.Pos(), obj: obj, use: node});
+			}
+		}
+		return true;
+	});
+	// events at the same position, i.e. gcm.Seal(nonce, nonce, ...), keep the order they were found in
+	sort.SliceStable(events, func(i, j int) bool { return events[i].pos < events[j].pos });
+
+	scope := f.pkg.typePkg.Scope();
+	reported := make(map[types.Object]bool);
+	lastUse := make(map[types.Object]bool);
+	filled := make(map[types.Object]bool);
+	for _, ev := range events {
+		if ev.use == nil {
+			filled[ev.obj] = true;
+			lastUse[ev.obj] = false;
+			continue;
+		}
+		if reported[ev.obj] {
+			continue;
+		}
+		switch {
+		case ev.obj.Parent() == scope:
+			reported[ev.obj] = true;
+			f.Reportf(ev.pos, "nonce/IV in a package level variable is reused: %s", f.ASTString(ev.use));
+		case lastUse[ev.obj]:
+			reported[ev.obj] = true;
+			f.Reportf(ev.pos, "nonce/IV reused without being refreshed: %s", f.ASTString(ev.use));
+		case !filled[ev.obj] && !isParam(f, fun, ev.obj):
+			reported[ev.obj] = true;
+			f.Reportf(ev.pos, "nonce/IV is never randomized: %s", f.ASTString(ev.use));
+		case inLoopWithoutFill(ev, loops, events):
+			reported[ev.obj] = true;
+			f.Reportf(ev.pos, "nonce/IV reused in a loop: %s", f.ASTString(ev.use));
+		}
+		lastUse[ev.obj] = true;
+	}
+}
+
+// isParam checks if a variable is a parameter of the function, those come from the caller
+func isParam(f *File, fun *ast.FuncDecl, obj types.Object) bool {
+	return fun.Type.Pos() <= obj.Pos() && obj.Pos() < fun.Type.End();
+}
+
+// inLoopWithoutFill checks if a nonce is used inside a loop it was declared outside of
+// without being refreshed somewhere in that loop
+func inLoopWithoutFill(use nonceEvent, loops []ast.Node, events []nonceEvent) bool {
+	for _, loop := range loops {
+		if use.pos < loop.Pos() || use.pos >= loop.End() || use.obj.Pos() >= loop.Pos() {
+			continue;
+		}
+		refreshed := false;
+		for _, ev := range events {
+			if ev.use == nil && ev.obj == use.obj && loop.Pos() <= ev.pos && ev.pos < loop.End() {
+				refreshed = true;
+				break;
+			}
+		}
+		if !refreshed {
+			return true;
+		}
+	}
+	return false;
+}
+
+func weakCryptoParamsCheck(f *File, node ast.Node) {
+	switch t := node.(type) {
+	case *ast.CallExpr:
+		checkCryptoCall(f, t);
+	case *ast.FuncDecl:
+		checkNonces(f, t);
+	}
+	return;
+}